@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"pewpew-watcher/platforms"
+	"pewpew-watcher/utils"
+	"strings"
+)
+
+type globalOptions struct {
+	configPath string
+	dbPath     string
+	platforms  string
+}
+
+func (o *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configPath, "config", o.configPath, "path to the config file")
+	fs.StringVar(&o.dbPath, "db", o.dbPath, "database path (overrides database.path)")
+	fs.StringVar(&o.platforms, "platform", o.platforms, "comma-separated list of platforms to target")
+}
+
+// readConfig returns the config with the command-line overrides applied,
+// along with every problem found in it.
+func (o *globalOptions) readConfig() (*utils.Config, utils.ConfigProblems, error) {
+	config, problems, err := utils.ReadConfig(o.configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.dbPath != "" {
+		config.Database.Path = o.dbPath
+	}
+	problems = append(problems, config.Validate(platforms.Names())...)
+	return config, problems, nil
+}
+
+func (o *globalOptions) loadConfig() (*utils.Config, error) {
+	config, problems, err := o.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %w\nRun `pewpew-watcher config validate` after fixing them", o.configPath, problems)
+	}
+
+	log.Printf("🔧 Notifiers loaded: %d", len(utils.Notifiers(config)))
+	return config, nil
+}
+
+func (o *globalOptions) platformFilter() ([]string, error) {
+	if o.platforms == "" {
+		return nil, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(o.platforms, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, exists := platforms.Get(name); !exists {
+			return nil, fmt.Errorf("unknown platform %q (available: %s)", name, strings.Join(platforms.Names(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// openDatabase brings the schema up to date when migrate is set, which only
+// the commands that sync do. Otherwise the database must already exist and be
+// up to date, so that read-only commands and dry runs never change it.
+func (o *globalOptions) openDatabase(ctx context.Context, config *utils.Config, migrate bool) (*sql.DB, error) {
+	if !migrate {
+		if _, err := os.Stat(config.Database.Path); err != nil {
+			return nil, fmt.Errorf("database %s does not exist yet, run `pewpew-watcher run` first", config.Database.Path)
+		}
+	}
+
+	db, err := utils.OpenDatabase(config.Database.Path)
+	if err != nil {
+		return nil, err
+	}
+	if migrate {
+		err = utils.MigrateDatabase(ctx, db, config.Database.Path)
+	} else {
+		err = utils.CheckSchema(ctx, db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, opts *globalOptions, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "[--no-startup]", "Sync every enabled platform once and exit (default)", runOnce},
+		{"watch", "[--no-startup]", "Keep running and sync platforms on their schedule", runWatch},
+		{"list", "", "List stored programs", runList},
+		{"show", "<program>", "Show a stored program by key, handle or name", runShow},
+		{"search", "<query>", "Search stored programs by name, URL or scope", runSearch},
+		{"history", "<program> [--since 168h] [--limit n] [--format text|json]", "Show every recorded change to a program", runHistory},
+		{"export", "[--format json|csv] [--output file]", "Export stored programs", runExport},
+		{"outbox", "[--status pending|sent|dead|all]", "List queued alert deliveries", runOutbox},
+		{"outbox retry", "[id...]", "Requeue dead alerts (all of them without ids)", runOutboxRetry},
+		{"config validate", "", "Check the config file and list every problem", runConfigValidate},
+		{"notify test", "", "Send a test notification to every configured channel", runNotifyTest},
+		{"db migrate", "[--status]", "Create or upgrade the database schema", runDBMigrate},
+		{"help", "", "Show this help", runHelp},
+	}
+}
+
+func runCLI(ctx context.Context, args []string) error {
+	opts := &globalOptions{configPath: "config.json"}
+
+	fs := flag.NewFlagSet("pewpew-watcher", flag.ContinueOnError)
+	opts.register(fs)
+	fs.Usage = func() { printUsage(fs.Output()) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		rest = []string{"run"}
+	}
+
+	cmd, cmdArgs := findCommand(rest)
+	if cmd == nil {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", rest[0])
+	}
+	return cmd.run(ctx, opts, cmdArgs)
+}
+
+func findCommand(args []string) (*command, []string) {
+	if len(args) >= 2 {
+		for _, cmd := range commands {
+			if cmd.name == args[0]+" "+args[1] {
+				return cmd, args[2:]
+			}
+		}
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, args[1:]
+		}
+	}
+	return nil, nil
+}
+
+// newFlagSet also registers the global flags so they are accepted after the
+// subcommand as well as before it.
+func newFlagSet(name string, opts *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts.register(fs)
+	fs.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: pewpew-watcher %s %s\n\n%s\n\n", cmd.name, cmd.args, cmd.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags allows flags to follow positional arguments (e.g. `show acme
+// --db other.db`) and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: pewpew-watcher [--config path] [--db path] [--platform names] <command> [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs := flag.NewFlagSet("pewpew-watcher", flag.ContinueOnError)
+	(&globalOptions{configPath: "config.json"}).register(fs)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+func runHelp(ctx context.Context, opts *globalOptions, args []string) error {
+	printUsage(os.Stdout)
+	return nil
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"pewpew-watcher/utils"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// withDatabase loads the config and opens the database for the commands
+// below.
+func withDatabase(ctx context.Context, opts *globalOptions, fn func(config *utils.Config, db *sql.DB) error) error {
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := opts.openDatabase(ctx, config, false)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+	defer db.Close()
+
+	return fn(config, db)
+}
+
+func requireArg(args []string, name string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("missing %s argument", name)
+	}
+	return strings.Join(args, " "), nil
+}
+
+func runList(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("list", opts)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	only, err := opts.platformFilter()
+	if err != nil {
+		return err
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		programs, err := utils.ListPrograms(ctx, db, only)
+		if err != nil {
+			return err
+		}
+		printProgramTable(os.Stdout, programs)
+		return nil
+	})
+}
+
+func runSearch(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("search", opts)
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	query, err := requireArg(rest, "query")
+	if err != nil {
+		return err
+	}
+	only, err := opts.platformFilter()
+	if err != nil {
+		return err
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		programs, err := utils.SearchPrograms(ctx, db, query, only)
+		if err != nil {
+			return err
+		}
+		printProgramTable(os.Stdout, programs)
+		return nil
+	})
+}
+
+func printProgramTable(w io.Writer, programs []*utils.Program) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PLATFORM\tTYPE\tNAME\tURL")
+	for _, program := range programs {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", program.Platform, program.Type, program.Name, program.URL)
+	}
+	table.Flush()
+	fmt.Fprintf(w, "\n%d program(s)\n", len(programs))
+}
+
+func runShow(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("show", opts)
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	ref, err := requireArg(rest, "program")
+	if err != nil {
+		return err
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		program, err := findProgram(ctx, db, ref)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Name:       %s\n", program.Name)
+		fmt.Printf("Platform:   %s\n", program.Platform)
+		fmt.Printf("Type:       %s\n", program.Type)
+		fmt.Printf("URL:        %s\n", program.URL)
+		fmt.Printf("Key:        %s\n", program.Key)
+		if reward, _ := utils.DeserializeReward(program.Reward); reward.Min != "" || reward.Max != "" {
+			fmt.Printf("Reward:     %s - %s\n", reward.Min, reward.Max)
+		}
+		fmt.Printf("First seen: %s\n", program.CreatedAt)
+		fmt.Printf("Changed:    %s\n", program.LastChangedAt)
+		fmt.Printf("Last seen:  %s\n", program.LastSeenAt)
+
+		scope, _ := utils.DeserializeScope(program.Scope)
+		fmt.Printf("\nScope (%d):\n", len(scope))
+		for _, target := range utils.SortedTargets(scope) {
+			fmt.Printf("  - %s%s\n", target, targetAttributes(target))
+			if target.Instruction != "" {
+				fmt.Printf("      %s\n", strings.ReplaceAll(target.Instruction, "\n", "\n      "))
+			}
+		}
+
+		outOfScope, _ := utils.DeserializeScope(program.OutOfScope)
+		if len(outOfScope) > 0 {
+			fmt.Printf("\nOut of scope (%d):\n", len(outOfScope))
+			for _, target := range utils.SortedTargets(outOfScope) {
+				fmt.Printf("  - %s\n", target)
+			}
+		}
+		return nil
+	})
+}
+
+func targetAttributes(target utils.Target) string {
+	var attributes []string
+	if target.EligibleForBounty {
+		attributes = append(attributes, "bounty")
+	}
+	if target.MaxSeverity != "" {
+		attributes = append(attributes, "max "+target.MaxSeverity)
+	}
+	if target.Category != "" {
+		attributes = append(attributes, target.Category)
+	}
+	if target.Tier != "" {
+		attributes = append(attributes, target.Tier)
+	}
+	if len(attributes) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attributes, ", ") + "]"
+}
+
+func runHistory(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("history", opts)
+	since := fs.Duration("since", 0, "only show changes from this long ago (e.g. 168h)")
+	limit := fs.Int("limit", 0, "only show the most recent changes")
+	format := fs.String("format", "text", "output format: text or json")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	ref, err := requireArg(rest, "program")
+	if err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown history format %q", *format)
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		// Removed programs are gone from the programs table but not from
+		// their history, so fall back to matching the events directly.
+		query := utils.EventQuery{Program: ref, Limit: *limit}
+		program, err := findProgram(ctx, db, ref)
+		if err == nil {
+			query.Program = program.Key
+		}
+		if *since > 0 {
+			query.Since = time.Now().Add(-*since)
+		}
+
+		events, err := utils.QueryEvents(ctx, db, query)
+		if err != nil {
+			return err
+		}
+
+		if *format == "json" {
+			if events == nil {
+				events = []*utils.ProgramEvent{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(events)
+		}
+
+		if len(events) == 0 {
+			if program == nil {
+				return fmt.Errorf("no program matches %q", ref)
+			}
+			fmt.Printf("%s (%s)\n\n", program.Name, program.Platform)
+			fmt.Printf("%s  🎉 first seen\n", program.CreatedAt)
+			fmt.Println("\nNo changes recorded.")
+			return nil
+		}
+
+		last := events[len(events)-1]
+		fmt.Printf("%s (%s)\n\n", last.Program, last.Platform)
+		for _, event := range events {
+			fmt.Printf("%s  %s\n", event.CreatedAt, describeEvent(event))
+		}
+		return nil
+	})
+}
+
+func describeEvent(event *utils.ProgramEvent) string {
+	var before, after any
+	json.Unmarshal(event.Before, &before)
+	json.Unmarshal(event.After, &after)
+
+	switch event.Kind {
+	case utils.EventNew:
+		var program struct {
+			Type  string         `json:"type"`
+			Scope []utils.Target `json:"scope"`
+		}
+		json.Unmarshal(event.After, &program)
+		return fmt.Sprintf("🎉 first seen (%s, %d targets)", program.Type, len(program.Scope))
+	case utils.EventRemoved:
+		return "🗑️ removed"
+	case utils.EventRenamed:
+		return fmt.Sprintf("🏷️ renamed: %v → %v", before, after)
+	case utils.EventMoved:
+		return fmt.Sprintf("🔀 moved: %v → %v", before, after)
+	case utils.EventScopeAdded:
+		return fmt.Sprintf("🆕 scope added: %v", after)
+	case utils.EventScopeRemoved:
+		return fmt.Sprintf("➖ scope removed: %v", before)
+	case utils.EventScopeChanged:
+		return fmt.Sprintf("✏️ scope changed: %v → %v", before, after)
+	case utils.EventAttributeChanged:
+		var old, changed map[string]string
+		json.Unmarshal(event.Before, &old)
+		json.Unmarshal(event.After, &changed)
+		for attribute, value := range changed {
+			if attribute != "target" {
+				return fmt.Sprintf("🎚️ %s: %s %s → %s", changed["target"], attribute, old[attribute], value)
+			}
+		}
+	case utils.EventOutOfScopeAdded:
+		return fmt.Sprintf("🚫 moved out of scope: %v", after)
+	case utils.EventOutOfScopeRemoved:
+		return fmt.Sprintf("↩️ no longer out of scope: %v", before)
+	case utils.EventTypeChanged:
+		return fmt.Sprintf("🔄 type: %v → %v", before, after)
+	case utils.EventRewardChanged:
+		var old, changed utils.Reward
+		json.Unmarshal(event.Before, &old)
+		json.Unmarshal(event.After, &changed)
+		return fmt.Sprintf("💰 bounty: %s - %s → %s - %s", old.Min, old.Max, changed.Min, changed.Max)
+	}
+	return event.Kind
+}
+
+func findProgram(ctx context.Context, db *sql.DB, ref string) (*utils.Program, error) {
+	program, err := utils.FindProgram(ctx, db, ref)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no program matches %q", ref)
+	}
+	return program, err
+}
+
+type exportedProgram struct {
+	Name          string         `json:"name"`
+	Platform      string         `json:"platform"`
+	Type          string         `json:"type"`
+	URL           string         `json:"url"`
+	Key           string         `json:"key"`
+	Scope         []utils.Target `json:"scope"`
+	OutOfScope    []utils.Target `json:"out_of_scope"`
+	Reward        utils.Reward   `json:"reward"`
+	CreatedAt     string         `json:"created_at"`
+	UpdatedAt     string         `json:"updated_at"`
+	LastChangedAt string         `json:"last_changed_at"`
+	LastSeenAt    string         `json:"last_seen_at"`
+}
+
+func runExport(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("export", opts)
+	format := fs.String("format", "json", "output format: json or csv")
+	output := fs.String("output", "", "write to this file instead of stdout")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	only, err := opts.platformFilter()
+	if err != nil {
+		return err
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		programs, err := utils.ListPrograms(ctx, db, only)
+		if err != nil {
+			return err
+		}
+
+		exported := make([]exportedProgram, 0, len(programs))
+		for _, program := range programs {
+			scope, _ := utils.DeserializeScope(program.Scope)
+			outOfScope, _ := utils.DeserializeScope(program.OutOfScope)
+			reward, _ := utils.DeserializeReward(program.Reward)
+			exported = append(exported, exportedProgram{
+				Name:          program.Name,
+				Platform:      program.Platform,
+				Type:          program.Type,
+				URL:           program.URL,
+				Key:           program.Key,
+				Scope:         utils.SortedTargets(scope),
+				OutOfScope:    utils.SortedTargets(outOfScope),
+				Reward:        reward,
+				CreatedAt:     program.CreatedAt,
+				UpdatedAt:     program.UpdatedAt,
+				LastChangedAt: program.LastChangedAt,
+				LastSeenAt:    program.LastSeenAt,
+			})
+		}
+
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+
+		if *format == "csv" {
+			err = writeCSV(w, exported)
+		} else {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(exported)
+		}
+		if err != nil {
+			return err
+		}
+
+		if *output != "" {
+			log.Printf("📦 Exported %d program(s) to %s", len(exported), *output)
+		}
+		return nil
+	})
+}
+
+func writeCSV(w io.Writer, programs []exportedProgram) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"platform", "name", "type", "url", "key", "reward_min", "reward_max", "scope", "out_of_scope", "created_at", "updated_at", "last_changed_at", "last_seen_at"})
+	for _, program := range programs {
+		writer.Write([]string{
+			program.Platform, program.Name, program.Type, program.URL, program.Key,
+			program.Reward.Min, program.Reward.Max, joinTargets(program.Scope), joinTargets(program.OutOfScope),
+			program.CreatedAt, program.UpdatedAt, program.LastChangedAt, program.LastSeenAt,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func joinTargets(targets []utils.Target) string {
+	values := make([]string, len(targets))
+	for i, target := range targets {
+		values[i] = target.String()
+	}
+	return strings.Join(values, "; ")
+}
+
+func runConfigValidate(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("config validate", opts)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, problems, err := opts.readConfig()
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		fmt.Printf("❌ %s has %d problem(s):\n", opts.configPath, len(problems))
+		for _, problem := range problems {
+			fmt.Printf("  %s: %s\n", problem.Path, problem.Message)
+		}
+		return fmt.Errorf("%s is invalid", opts.configPath)
+	}
+
+	fmt.Printf("✅ %s is valid\n", opts.configPath)
+	return nil
+}
+
+func runNotifyTest(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("notify test", opts)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	sendTestNotification(ctx, config)
+	return nil
+}
+
+func runDBMigrate(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("db migrate", opts)
+	status := fs.Bool("status", false, "only list the pending migrations")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := utils.OpenDatabase(config.Database.Path)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+	defer db.Close()
+
+	pending, current, err := utils.PendingMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		log.Printf("💾 Database schema at %s is up to date (version %d)", config.Database.Path, current)
+		return nil
+	}
+
+	fmt.Printf("Schema version %d, %d pending migration(s):\n", current, len(pending))
+	for _, migration := range pending {
+		fmt.Printf("  %04d_%s\n", migration.Version, migration.Name)
+	}
+	if *status {
+		return nil
+	}
+	return utils.MigrateDatabase(ctx, db, config.Database.Path)
+}
+
+func runOutbox(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("outbox", opts)
+	status := fs.String("status", "pending", "entries to list: pending, sent, dead or all")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	filter := *status
+	switch filter {
+	case utils.OutboxPending, utils.OutboxSent, utils.OutboxDead:
+	case "all":
+		filter = ""
+	default:
+		return fmt.Errorf("unknown status %q (expected pending, sent, dead or all)", *status)
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		entries, err := utils.ListOutbox(ctx, db, filter)
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "ID\tSTATUS\tNOTIFIER\tPLATFORM\tPROGRAM\tATTEMPTS\tNEXT ATTEMPT\tLAST ERROR")
+		for _, entry := range entries {
+			next := entry.NextAttemptAt
+			if entry.Status != utils.OutboxPending {
+				next = "-"
+			}
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", entry.ID, entry.Status, entry.Notifier,
+				entry.Alert.Program.Platform, entry.Alert.Program.Name, entry.Attempts, next, entry.LastError)
+		}
+		return table.Flush()
+	})
+}
+
+func runOutboxRetry(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("outbox retry", opts)
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	for _, arg := range rest {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid outbox id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	return withDatabase(ctx, opts, func(config *utils.Config, db *sql.DB) error {
+		requeued, err := utils.RetryOutbox(ctx, db, ids)
+		if err != nil {
+			return err
+		}
+		log.Printf("📮 Requeued %d dead alert(s); they are sent on the next run", requeued)
+		return nil
+	})
+}
@@ -1,46 +1,51 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
 	"pewpew-watcher/platforms"
 	"pewpew-watcher/utils"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-
-	_ "modernc.org/sqlite"
 )
 
-type Config struct {
-	DiscordWebhook string                    `json:"DiscordWebhook"`
-	Telegram       utils.TelegramConfig      `json:"telegram"`
-	Database       utils.DatabaseConfig      `json:"database"`
-	Platforms      map[string]utils.Platform `json:"platforms"`
-}
-
-func loadConfig() *Config {
-	file, err := ioutil.ReadFile("config.json")
-	if err != nil {
-		log.Fatal("  Config file error:", err)
-	}
+const (
+	defaultWatchInterval = 30 * time.Minute
+	defaultWatchJitter   = 2 * time.Minute
+	defaultConcurrency   = 4
+)
 
-	var config Config
-	if err := json.Unmarshal(file, &config); err != nil {
-		log.Fatal("  Config parse error:", err)
-	}
+func main() {
+	ctx := shutdownContext()
 
-	log.Printf("🔧 Webhook loaded: %v", config.DiscordWebhook != "")
-	if config.DiscordWebhook != "" {
-		log.Printf("🔧 Webhook preview: %s...", config.DiscordWebhook[:30])
+	if err := runCLI(ctx, os.Args[1:]); err != nil {
+		log.Printf("  Error: %v", err)
+		os.Exit(1)
 	}
+}
 
-	return &config
+// shutdownContext is cancelled on the first SIGINT/SIGTERM; a second one
+// falls through to the default handler and kills the process.
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		log.Println("🛑 Shutdown requested, finishing in-flight work (press Ctrl+C again to force)")
+		cancel()
+	}()
+	return ctx
 }
 
-func main() {
+func printBanner() {
 	fmt.Println(`
 	██████╗ ███████╗ ██╗    ██╗██████╗ ███████╗ ██╗    ██╗
 	██╔══██╗██╔════╝ ██║    ██║██╔══██╗██╔════╝ ██║    ██║
@@ -52,46 +57,153 @@ func main() {
 	🚀 PewPew Watcher v1.0 by M-thefl
 	📧 GitHub: https://github.com/M-thefl
 	`)
+}
 
-	config := loadConfig()
+type monitorSession struct {
+	config   *utils.Config
+	writer   *utils.DBWriter
+	names    []string
+	firstRun bool
+	close    func()
+}
 
-	db, err := sql.Open("sqlite", config.Database.Path)
+// startMonitoring prints the banner and migrates the database unless dryRun
+// is set, and skips the startup message and API check when noStartup is set.
+func startMonitoring(ctx context.Context, opts *globalOptions, noStartup, dryRun bool) (*monitorSession, error) {
+	config, err := opts.loadConfig()
 	if err != nil {
-		log.Fatal("  Database connection error:", err)
+		return nil, err
+	}
+	only, err := opts.platformFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		printBanner()
 	}
-	defer db.Close()
 
-	utils.InitDatabase(db)
+	db, err := opts.openDatabase(ctx, config, !dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("database connection error: %w", err)
+	}
+	writer := utils.NewDBWriter(db)
 
-	firstRun := utils.IsFirstRun(db)
+	firstRun := utils.IsFirstRun(ctx, db, "")
 	log.Printf("🎯 First run: %v", firstRun)
 
-	utilsConfig := &utils.Config{
-		DiscordWebhook: config.DiscordWebhook,
-		Telegram:       config.Telegram,
-		Database:       config.Database,
-		Platforms:      config.Platforms,
-	}
+	names := enabledPlatforms(config, only)
 
-	startTime := time.Now()
+	if !noStartup {
+		log.Println("📨 Sending startup message...")
+		utils.SendStartupMessage(ctx, config, firstRun)
+		log.Println(" 🍀 Startup message process completed")
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+		}
+		testAllAPIs(ctx, config, names)
+	}
 
-	log.Println("📨 Sending startup message...")
-	utils.SendStartupMessage(utilsConfig, firstRun)
-	log.Println(" 🍀 Startup message process completed")
-	time.Sleep(2 * time.Second)
 	log.Println("🕵️ Starting platform monitoring...")
-	testAllAPIs(config.Platforms)
 	log.Printf("🔧 First run mode: %v - %s", firstRun, getFirstRunMessage(firstRun))
-	monitorPlatforms(utilsConfig, db, firstRun)
 
-	if firstRun {
+	return &monitorSession{
+		config:   config,
+		writer:   writer,
+		names:    names,
+		firstRun: firstRun,
+		close: func() {
+			writer.Close()
+			db.Close()
+		},
+	}, nil
+}
+
+func runOnce(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("run", opts)
+	noStartup := fs.Bool("no-startup", false, "skip the startup message, API check and first-run test notification")
+	dryRun := fs.Bool("dry-run", false, "compute alerts against the stored state without saving or sending them")
+	format := fs.String("format", "text", "dry-run output format: text or json")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown dry-run format %q", *format)
+	}
+	if *dryRun {
+		*noStartup = true
+	}
+
+	session, err := startMonitoring(ctx, opts, *noStartup, *dryRun)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	startTime := time.Now()
+	results := monitorPlatforms(ctx, session.config, session.writer, session.names, platforms.SyncOptions{DryRun: *dryRun})
+
+	if *dryRun {
+		return printDryRun(results, *format)
+	}
+
+	if session.firstRun && !*noStartup && ctx.Err() == nil {
 		log.Println("🧪Sending test notification after first run...")
-		sendTestNotification(utilsConfig)
+		sendTestNotification(ctx, session.config)
 	}
 
 	duration := time.Since(startTime)
+	if ctx.Err() != nil {
+		log.Printf("🛑 Monitoring interrupted after %v", duration)
+		return nil
+	}
 	log.Printf(" 🍀 Monitoring completed in %v", duration)
 	log.Println("🎉 PewPew Watcher finished successfully!")
+	return nil
+}
+
+func runWatch(ctx context.Context, opts *globalOptions, args []string) error {
+	fs := newFlagSet("watch", opts)
+	noStartup := fs.Bool("no-startup", false, "skip the startup message, API check and first-run test notification")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	session, err := startMonitoring(ctx, opts, *noStartup, false)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	config := session.config
+	firstRun := session.firstRun
+
+	defaultInterval := utils.ParseDuration(config.Watch.Interval, defaultWatchInterval)
+	scheduler := utils.NewScheduler(utils.ParseDuration(config.Watch.Jitter, defaultWatchJitter))
+
+	for _, name := range session.names {
+		interval := utils.ParseDuration(config.Platforms[name].Interval, defaultInterval)
+		scheduler.Add(name, interval)
+		log.Printf("⏰ Watching %s every %v", strings.Title(name), interval)
+	}
+	if len(session.names) == 0 {
+		log.Println(" 🍀 No platforms to watch. Check your config.json!")
+		return nil
+	}
+
+	scheduler.Run(ctx, func(due []string) {
+		monitorPlatforms(ctx, config, session.writer, due, platforms.SyncOptions{})
+
+		if firstRun && !*noStartup && ctx.Err() == nil {
+			log.Println("🧪Sending test notification after first run...")
+			sendTestNotification(ctx, config)
+		}
+		firstRun = false
+	})
+
+	log.Println("👋 PewPew Watcher stopped")
+	return nil
 }
 
 func getFirstRunMessage(firstRun bool) string {
@@ -101,56 +213,120 @@ func getFirstRunMessage(firstRun bool) string {
 	return "Monitoring for changes and sending notifications"
 }
 
-func testAllAPIs(platforms map[string]utils.Platform) {
+func concurrency(config *utils.Config) int {
+	if config.Concurrency > 0 {
+		return config.Concurrency
+	}
+	return defaultConcurrency
+}
+
+func testAllAPIs(ctx context.Context, config *utils.Config, names []string) {
 	log.Println("🧪 Testing API connections...")
 
-	for name, platform := range platforms {
-		if !platform.Monitor {
-			log.Printf("⏭️ %s: Monitoring disabled", strings.Title(name))
-			continue
+	slots := make(chan struct{}, concurrency(config))
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string, platform utils.Platform) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			log.Printf("🔗 Testing %s API...", strings.Title(name))
+			body, err := utils.FetchData(ctx, platform.URL)
+			if err != nil {
+				log.Printf(" %s: %v", strings.Title(name), err)
+			} else {
+				log.Printf("🍀 %s: Success (%d bytes)", strings.Title(name), len(body))
+			}
+		}(name, config.Platforms[name])
+	}
+
+	wg.Wait()
+}
+
+// enabledPlatforms returns the platforms to sync. Naming platforms explicitly
+// (--platform) overrides their monitor flag.
+func enabledPlatforms(config *utils.Config, only []string) []string {
+	if len(only) > 0 {
+		var names []string
+		for _, name := range only {
+			if _, exists := config.Platforms[name]; !exists {
+				log.Printf("⚠️ %s is not configured in config.json", strings.Title(name))
+				continue
+			}
+			names = append(names, name)
 		}
+		return names
+	}
 
-		log.Printf("🔗 Testing %s API...", strings.Title(name))
-		body, err := utils.FetchData(platform.URL)
-		if err != nil {
-			log.Printf(" %s: %v", strings.Title(name), err)
-		} else {
-			log.Printf("🍀 %s: Success (%d bytes)", strings.Title(name), len(body))
+	for name, platformConfig := range config.Platforms {
+		if _, exists := platforms.Get(name); !exists && platformConfig.Monitor {
+			log.Printf("⚠️ %s is enabled but has no adapter", strings.Title(name))
 		}
-		time.Sleep(1 * time.Second)
 	}
-}
 
-func monitorPlatforms(config *utils.Config, db *sql.DB, firstRun bool) {
-	platformsList := []struct {
-		name    string
-		monitor func(*utils.Config, *sql.DB, bool)
-	}{
-		{"hackerone", platforms.MonitorHackerOne},
-		{"bugcrowd", platforms.MonitorBugcrowd},
-		{"intigriti", platforms.MonitorIntigriti},
-		// {"yeswehack", platforms.MonitorYesWeHack},
+	var names []string
+	for _, name := range platforms.Names() {
+		if platformConfig, exists := config.Platforms[name]; !exists || !platformConfig.Monitor {
+			log.Printf("⏭️ Skipping %s (disabled)", strings.Title(name))
+			continue
+		}
+		names = append(names, name)
 	}
+	return names
+}
+
+func monitorPlatforms(ctx context.Context, config *utils.Config, writer *utils.DBWriter, names []string, opts platforms.SyncOptions) []*platforms.SyncResult {
+	opts.Concurrency = concurrency(config)
+	results := platforms.SyncAll(ctx, names, config, writer, opts)
 
 	successCount := 0
-	for _, platform := range platformsList {
-		if platformConfig, exists := config.Platforms[platform.name]; exists && platformConfig.Monitor {
-			log.Printf("🔍 Monitoring %s...", strings.Title(platform.name))
-			platform.monitor(config, db, firstRun)
-			successCount++
-		} else {
-			log.Printf("⏭️ Skipping %s (disabled)", strings.Title(platform.name))
+	log.Println("📋 Run summary:")
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("   ❌ %s: %v", strings.Title(result.Platform), result.Err)
+			continue
 		}
+		successCount++
+		log.Printf("   ✅ %s: %d programs, %d new, %d updated, %d removed, %d alerts in %v",
+			strings.Title(result.Platform), result.Programs, result.New, result.Updated,
+			result.Removed, len(result.Alerts), result.Duration.Round(time.Millisecond))
 	}
 
 	if successCount == 0 {
 		log.Println(" 🍀 No platforms were monitored. Check your config.json!")
 	} else {
-		log.Printf("🎊 Successfully monitored %d platform(s)", successCount)
+		log.Printf("🎊 Successfully monitored %d of %d platform(s)", successCount, len(results))
+	}
+	return results
+}
+
+func printDryRun(results []*platforms.SyncResult, format string) error {
+	alerts := []*utils.Alert{}
+	for _, result := range results {
+		alerts = append(alerts, result.Alerts...)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(alerts)
+	}
+
+	for _, alert := range alerts {
+		fmt.Println(utils.FormatAlertText(alert))
 	}
+	log.Printf("🧪 Dry run: %d alert(s) would be sent, nothing was saved", len(alerts))
+	return nil
 }
 
-func sendTestNotification(config *utils.Config) {
+func sendTestNotification(ctx context.Context, config *utils.Config) {
 	testAlert := &utils.Alert{
 		Program: &utils.Program{
 			Name:     "PewPew Watcher Test",
@@ -165,6 +341,6 @@ func sendTestNotification(config *utils.Config) {
 	}
 
 	log.Println("🧪 Sending test notification...")
-	utils.SendAlert(testAlert, config)
+	utils.SendAlert(ctx, testAlert, config)
 	log.Println(" 🍀Test notification sent!")
 }
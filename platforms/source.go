@@ -0,0 +1,54 @@
+package platforms
+
+import (
+	"pewpew-watcher/utils"
+	"sort"
+)
+
+// Source turns a platform feed body into normalized programs. Everything
+// else (diffing, persistence, alerting) is handled by Sync.
+type Source interface {
+	Name() string
+	Parse(body []byte) ([]*ParsedProgram, error)
+}
+
+type ParsedProgram struct {
+	// Handle is the platform's stable identifier for the program (e.g. the
+	// HackerOne handle). Leave it empty if the feed has none.
+	Handle     string
+	Name       string
+	URL        string
+	Type       string
+	Logo       string
+	Scope      map[string]utils.Target
+	OutOfScope map[string]utils.Target
+	Reward     utils.Reward
+}
+
+var sources = make(map[string]Source)
+
+func Register(source Source) {
+	sources[source.Name()] = source
+}
+
+func Get(name string) (Source, bool) {
+	source, exists := sources[name]
+	return source, exists
+}
+
+func Names() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addTarget keys a scope entry by its identity (see utils.ScopeKey).
+func addTarget(scope map[string]utils.Target, target utils.Target) {
+	if target.Asset == "" {
+		return
+	}
+	scope[target.Key()] = target
+}
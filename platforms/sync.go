@@ -0,0 +1,425 @@
+package platforms
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"pewpew-watcher/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alerts that were already queued are still delivered after shutdown has
+// been requested, for at most this long.
+const alertFlushTimeout = 30 * time.Second
+
+type SyncOptions struct {
+	Concurrency int
+	// DryRun applies each platform in a transaction that is rolled back
+	// and sends nothing; the would-be alerts are left in SyncResult.Alerts.
+	DryRun bool
+}
+
+type SyncResult struct {
+	Platform string
+	Programs int
+	New      int
+	Updated  int
+	Removed  int
+	Alerts   []*utils.Alert
+	Duration time.Duration
+	Err      error
+}
+
+// SyncAll fetches and parses platforms in parallel, at most
+// opts.Concurrency at a time. Results are returned in the order of names.
+func SyncAll(ctx context.Context, names []string, config *utils.Config, writer *utils.DBWriter, opts SyncOptions) []*SyncResult {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]*SyncResult, len(names))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		source, exists := Get(name)
+		if !exists {
+			results[i] = &SyncResult{Platform: name, Err: fmt.Errorf("no adapter registered for %s", name)}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = &SyncResult{Platform: source.Name(), Err: fmt.Errorf("skipped: %w", ctx.Err())}
+				return
+			}
+			defer func() { <-slots }()
+
+			if ctx.Err() != nil {
+				results[i] = &SyncResult{Platform: source.Name(), Err: fmt.Errorf("skipped: %w", ctx.Err())}
+				return
+			}
+
+			log.Printf("🔍 Monitoring %s...", strings.Title(source.Name()))
+			results[i] = Sync(ctx, source, config, writer, opts)
+		}(i, source)
+	}
+
+	wg.Wait()
+
+	if !opts.DryRun {
+		// Until shutdown, only the notifiers' own request timeouts apply.
+		deliverCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		stop := context.AfterFunc(ctx, func() {
+			timer := time.AfterFunc(alertFlushTimeout, cancel)
+			context.AfterFunc(deliverCtx, func() { timer.Stop() })
+		})
+		defer stop()
+		utils.DeliverOutbox(deliverCtx, writer, config)
+	}
+	return results
+}
+
+func Sync(ctx context.Context, source Source, config *utils.Config, writer *utils.DBWriter, opts SyncOptions) *SyncResult {
+	name := source.Name()
+	title := strings.Title(name)
+	platformConfig := config.Platforms[name]
+	startTime := time.Now()
+	result := &SyncResult{Platform: name}
+
+	body, err := utils.FetchData(ctx, platformConfig.URL)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to fetch %s data: %w", title, err)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	log.Printf("🔍 %s JSON preview: %s", title, preview(body, 200))
+
+	programs, err := source.Parse(body)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to parse %s data: %w", title, err)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+	log.Printf("📊 Found %d %s programs", len(programs), title)
+
+	// Once the writer picks the platform up it is applied in full, even if
+	// shutdown is requested meanwhile. Nothing is kept unless every program
+	// was applied, so a failure halfway is simply retried on the next run.
+	// The alerts are queued in the same transaction and delivered by
+	// SyncAll, so a change is never stored without its alert.
+	var alerts []*utils.Alert
+	err = writer.Do(ctx, func(db *sql.DB) error {
+		writeCtx := context.WithoutCancel(ctx)
+		tx, err := db.BeginTx(writeCtx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		alerts, err = applyPrograms(writeCtx, name, programs, tx, platformConfig, result)
+		if err != nil || opts.DryRun {
+			return err
+		}
+		if err := utils.EnqueueAlerts(writeCtx, tx, alerts, utils.Notifiers(config)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		*result = SyncResult{Platform: name}
+		result.Err = fmt.Errorf("failed to store %s data: %w", title, err)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	result.Alerts = alerts
+	result.Duration = time.Since(startTime)
+
+	log.Printf(" 🍀 %s: %d programs processed, %d new, %d updated, %d removed",
+		title, result.Programs, result.New, result.Updated, result.Removed)
+	return result
+}
+
+// applyPrograms diffs the parsed feed against the stored state and persists
+// it, along with the history of every change. It returns the alerts that
+// should be delivered once the transaction is committed.
+func applyPrograms(ctx context.Context, name string, programs []*ParsedProgram, db utils.DBTX,
+	platformConfig utils.Platform, result *SyncResult) ([]*utils.Alert, error) {
+
+	var alerts []*utils.Alert
+	currentKeys := make(map[string]bool)
+
+	// A platform with nothing stored yet (a fresh database, or one that was
+	// just enabled) is stored as the baseline without alerting.
+	firstRun := utils.IsFirstRun(ctx, db, name)
+	if firstRun {
+		log.Printf("🎯 %s: first run, storing programs without alerts", strings.Title(name))
+	}
+
+	for _, parsed := range programs {
+		if parsed.Name == "" || parsed.URL == "" {
+			log.Printf(" 🍀 Skipping program with missing name/url: name=%s, url=%s", parsed.Name, parsed.URL)
+			continue
+		}
+
+		key := utils.ProgramKey(name, parsed.Handle, parsed.Name, parsed.URL)
+		currentKeys[key] = true
+
+		newProgram := buildProgram(name, key, parsed)
+
+		existingProgram, err := utils.GetProgram(ctx, db, key)
+		if err == sql.ErrNoRows && parsed.Handle != "" {
+			existingProgram, err = adoptLegacyKey(ctx, db, name, key, parsed)
+		}
+		if err == sql.ErrNoRows {
+			alert := &utils.Alert{
+				Program:  newProgram,
+				IsNew:    true,
+				NewScope: utils.SortedScopeValues(parsed.Scope),
+			}
+
+			if err := utils.SaveProgram(ctx, db, newProgram); err != nil {
+				return nil, fmt.Errorf("failed to save new program %s: %w", parsed.Name, err)
+			}
+			if err := recordHistory(ctx, db, alert, nil); err != nil {
+				return nil, err
+			}
+
+			if filtered := utils.FilterAlert(alert, platformConfig.Notifications, firstRun); filtered != nil {
+				alerts = append(alerts, filtered)
+			}
+			result.New++
+			result.Programs++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load program %s: %w", parsed.Name, err)
+		}
+
+		alert := diffProgram(existingProgram, newProgram, parsed)
+		if alert != nil {
+			err = utils.SaveProgram(ctx, db, newProgram)
+		} else if !tracksOutOfScope(existingProgram) || !utils.IsCurrentScope(existingProgram.Scope) {
+			err = utils.SaveBaseline(ctx, db, key, newProgram.Scope, newProgram.OutOfScope)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update program %s: %w", parsed.Name, err)
+		}
+
+		if alert != nil {
+			if err := recordHistory(ctx, db, alert, existingProgram); err != nil {
+				return nil, err
+			}
+			if filtered := utils.FilterAlert(alert, platformConfig.Notifications, firstRun); filtered != nil {
+				alerts = append(alerts, filtered)
+			}
+			result.Updated++
+		}
+
+		result.Programs++
+	}
+
+	keys := make([]string, 0, len(currentKeys))
+	for key := range currentKeys {
+		keys = append(keys, key)
+	}
+	if err := utils.TouchPrograms(ctx, db, keys); err != nil {
+		return nil, fmt.Errorf("failed to update last seen: %w", err)
+	}
+
+	removedAlerts, err := checkRemovedPrograms(ctx, name, currentKeys, db, platformConfig, result)
+	if err != nil {
+		return nil, err
+	}
+	return append(alerts, removedAlerts...), nil
+}
+
+func buildProgram(platform, key string, parsed *ParsedProgram) *utils.Program {
+	scopeJSON, _ := utils.SerializeScope(parsed.Scope)
+	outOfScopeJSON, _ := utils.SerializeScope(parsed.OutOfScope)
+	rewardJSON, _ := utils.SerializeReward(parsed.Reward)
+
+	return &utils.Program{
+		Name:       parsed.Name,
+		URL:        parsed.URL,
+		Type:       parsed.Type,
+		Key:        key,
+		Platform:   platform,
+		Logo:       parsed.Logo,
+		Scope:      scopeJSON,
+		OutOfScope: outOfScopeJSON,
+		Reward:     rewardJSON,
+	}
+}
+
+// adoptLegacyKey moves a program stored under its old name|url key to the
+// handle-based key. A program renamed or moved since it was stored is found
+// by its URL or name instead.
+func adoptLegacyKey(ctx context.Context, db utils.DBTX, platform, key string, parsed *ParsedProgram) (*utils.Program, error) {
+	program, err := utils.GetProgram(ctx, db, utils.GenerateProgramKey(parsed.Name, parsed.URL))
+	if err == sql.ErrNoRows {
+		program, err = utils.FindLegacyProgram(ctx, db, platform, parsed.Name, parsed.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.RekeyProgram(ctx, db, program.Key, key); err != nil {
+		return nil, err
+	}
+	log.Printf("🔑 Adopted %s (stored as %s, %s) under %s", parsed.Name, program.Name, program.Key, key)
+	program.Key = key
+	return program, nil
+}
+
+// recordHistory stores every change in the alert, whether or not it is
+// going to be sent.
+func recordHistory(ctx context.Context, db utils.DBTX, alert *utils.Alert, previous *utils.Program) error {
+	if err := utils.RecordEvents(ctx, db, utils.AlertEvents(alert, previous)); err != nil {
+		return fmt.Errorf("failed to record history for %s: %w", alert.Program.Name, err)
+	}
+	return nil
+}
+
+// tracksOutOfScope is false for programs stored before out-of-scope assets
+// were recorded. Their exclusions are saved as a baseline without alerting,
+// as is a scope stored in an older format.
+func tracksOutOfScope(program *utils.Program) bool {
+	return program.OutOfScope != "" && program.OutOfScope != "[]"
+}
+
+// diffProgram returns nil when nothing tracked has changed.
+func diffProgram(existingProgram, newProgram *utils.Program, parsed *ParsedProgram) *utils.Alert {
+	alert := &utils.Alert{Program: newProgram}
+	hasChanged := false
+
+	if existingProgram.Name != newProgram.Name {
+		alert.PreviousName = existingProgram.Name
+		hasChanged = true
+	}
+
+	if existingProgram.URL != newProgram.URL {
+		alert.PreviousURL = existingProgram.URL
+		hasChanged = true
+	}
+
+	if existingProgram.Type != newProgram.Type {
+		alert.NewType = newProgram.Type
+		alert.PreviousType = existingProgram.Type
+		hasChanged = true
+	}
+
+	existingScope, err := utils.DeserializeScope(existingProgram.Scope)
+	if err != nil {
+		// An unreadable stored scope is not diffed; the current one is saved
+		// as the baseline instead (see applyPrograms).
+		existingScope = parsed.Scope
+	}
+	newScope, removedScope, changedScope, changedAttributes := utils.CompareScopes(existingScope, parsed.Scope)
+
+	if len(newScope) > 0 {
+		alert.NewScope = newScope
+		hasChanged = true
+	}
+
+	if len(removedScope) > 0 {
+		alert.RemovedScope = removedScope
+		hasChanged = true
+	}
+
+	if len(changedScope) > 0 {
+		alert.ChangedScope = changedScope
+		hasChanged = true
+	}
+
+	if len(changedAttributes) > 0 {
+		alert.ChangedAttributes = changedAttributes
+		hasChanged = true
+	}
+
+	if tracksOutOfScope(existingProgram) {
+		existingOutOfScope, _ := utils.DeserializeScope(existingProgram.OutOfScope)
+		excluded, readmitted, _, _ := utils.CompareScopes(existingOutOfScope, parsed.OutOfScope)
+
+		if len(excluded) > 0 {
+			alert.NewOutOfScope = excluded
+			hasChanged = true
+		}
+
+		if len(readmitted) > 0 {
+			alert.RemovedOutOfScope = readmitted
+			hasChanged = true
+		}
+	}
+
+	existingReward, _ := utils.DeserializeReward(existingProgram.Reward)
+	if existingReward.Min != parsed.Reward.Min || existingReward.Max != parsed.Reward.Max {
+		reward := parsed.Reward
+		alert.Reward = &reward
+		alert.PreviousReward = &existingReward
+		hasChanged = true
+	}
+
+	if !hasChanged {
+		return nil
+	}
+	return alert
+}
+
+func checkRemovedPrograms(ctx context.Context, platform string, currentKeys map[string]bool, db utils.DBTX,
+	platformConfig utils.Platform, result *SyncResult) ([]*utils.Alert, error) {
+
+	existingKeys, err := utils.GetAllProgramKeys(ctx, db, platform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing keys: %w", err)
+	}
+
+	var alerts []*utils.Alert
+	for _, existingKey := range existingKeys {
+		if currentKeys[existingKey] {
+			continue
+		}
+
+		removedProgram, err := utils.GetProgram(ctx, db, existingKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load removed program %s: %w", existingKey, err)
+		}
+
+		alert := &utils.Alert{
+			Program:   removedProgram,
+			IsRemoved: true,
+		}
+
+		if err := utils.DeleteProgram(ctx, db, existingKey); err != nil {
+			return nil, fmt.Errorf("failed to delete removed program %s: %w", existingKey, err)
+		}
+		if err := recordHistory(ctx, db, alert, nil); err != nil {
+			return nil, err
+		}
+
+		if filtered := utils.FilterAlert(alert, platformConfig.Notifications, false); filtered != nil {
+			alerts = append(alerts, filtered)
+		}
+		result.Removed++
+	}
+
+	return alerts, nil
+}
+
+func preview(body []byte, maxLength int) string {
+	if len(body) <= maxLength {
+		return string(body)
+	}
+	return string(body[:maxLength])
+}
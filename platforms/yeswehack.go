@@ -0,0 +1,119 @@
+package platforms
+
+import (
+	"fmt"
+	"log"
+	"pewpew-watcher/utils"
+
+	"github.com/tidwall/gjson"
+)
+
+type YesWeHack struct{}
+
+func init() {
+	Register(YesWeHack{})
+}
+
+func (YesWeHack) Name() string {
+	return "yeswehack"
+}
+
+func (YesWeHack) Parse(body []byte) ([]*ParsedProgram, error) {
+	programs := gjson.ParseBytes(body).Array()
+	log.Printf("📊 Found %d YesWeHack programs in root array", len(programs))
+
+	if len(programs) == 0 {
+		programs = gjson.GetBytes(body, "items").Array()
+		log.Printf("📊 Found %d YesWeHack programs in 'items' path", len(programs))
+	}
+
+	var parsed []*ParsedProgram
+	for _, program := range programs {
+		name := program.Get("title").String()
+		if name == "" {
+			name = program.Get("name").String()
+		}
+
+		if program.Get("disabled").Bool() {
+			log.Printf(" 🍀 Skipping disabled YesWeHack program: %s", name)
+			continue
+		}
+		if public := program.Get("public"); public.Exists() && !public.Bool() {
+			log.Printf(" 🍀 Skipping private YesWeHack program: %s", name)
+			continue
+		}
+
+		slug := program.Get("slug").String()
+		if slug == "" {
+			slug = program.Get("id").String()
+		}
+
+		url := ""
+		if slug != "" {
+			url = fmt.Sprintf("https://yeswehack.com/programs/%s", slug)
+		}
+
+		logo := "https://pbs.twimg.com/profile_images/1154580610072817664/5YjR6tTI_400x400.png"
+		if thumbnail := program.Get("thumbnail.url").String(); thumbnail != "" {
+			logo = thumbnail
+		}
+
+		programType := "vdp"
+		var reward utils.Reward
+
+		if program.Get("max_bounty").Float() > 0 {
+			programType = "rdp"
+			reward = utils.Reward{
+				Min: program.Get("min_bounty").String(),
+				Max: program.Get("max_bounty").String(),
+			}
+		} else if program.Get("bounty").Bool() {
+			programType = "rdp"
+		}
+
+		scope := make(map[string]utils.Target)
+		for _, target := range program.Get("targets.in_scope").Array() {
+			addTarget(scope, yesWeHackTarget(target))
+		}
+		if len(scope) == 0 {
+			for _, target := range program.Get("scopes").Array() {
+				addTarget(scope, yesWeHackTarget(target))
+			}
+		}
+
+		outOfScope := make(map[string]utils.Target)
+		for _, target := range program.Get("targets.out_of_scope").Array() {
+			addTarget(outOfScope, yesWeHackTarget(target))
+		}
+
+		parsed = append(parsed, &ParsedProgram{
+			Handle:     slug,
+			Name:       name,
+			URL:        url,
+			Type:       programType,
+			Logo:       logo,
+			Scope:      scope,
+			OutOfScope: outOfScope,
+			Reward:     reward,
+		})
+	}
+
+	return parsed, nil
+}
+
+func yesWeHackTarget(target gjson.Result) utils.Target {
+	targetName := target.Get("target").String()
+	if targetName == "" {
+		targetName = target.Get("scope").String()
+	}
+
+	targetType := target.Get("type").String()
+	if targetType == "" {
+		targetType = target.Get("scope_type").String()
+	}
+	if targetType == "" {
+		targetType = "unknown"
+	}
+
+	return utils.Target{Asset: targetName, Type: targetType}
+}
@@ -0,0 +1,407 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	DiscordWebhook string              `json:"DiscordWebhook" secret:"true"`
+	Telegram       TelegramConfig      `json:"telegram"`
+	Notifiers      []NotifierConfig    `json:"notifiers"`
+	Digest         DigestConfig        `json:"digest"`
+	Database       DatabaseConfig      `json:"database"`
+	Watch          WatchConfig         `json:"watch"`
+	Concurrency    int                 `json:"concurrency"`
+	Platforms      map[string]Platform `json:"platforms"`
+}
+
+type TelegramConfig struct {
+	BotToken string `json:"bot_token" secret:"true"`
+	ChatID   string `json:"chat_id" secret:"true"`
+}
+
+type DatabaseConfig struct {
+	Path string `json:"path"`
+}
+
+type WatchConfig struct {
+	Interval string `json:"interval"`
+	Jitter   string `json:"jitter"`
+}
+
+type Platform struct {
+	URL           string        `json:"url"`
+	Monitor       bool          `json:"monitor"`
+	Interval      string        `json:"interval"`
+	Notifications Notifications `json:"notifications"`
+}
+
+type Notifications struct {
+	NewProgram      bool `json:"new_program"`
+	RemovedProgram  bool `json:"removed_program"`
+	RenamedProgram  bool `json:"renamed_program" default:"true"`
+	NewScope        bool `json:"new_scope"`
+	RemovedScope    bool `json:"removed_scope"`
+	ChangedScope    bool `json:"changed_scope"`
+	AttributeChange bool `json:"attribute_change" default:"true"`
+	OutOfScope      bool `json:"out_of_scope" default:"true"`
+	NewType         bool `json:"new_type"`
+	RewardChange    bool `json:"reward_change" default:"true"`
+}
+
+// ConfigProblem is a single config error, located by its JSON path
+// (e.g. "platforms.hackerone.url").
+type ConfigProblem struct {
+	Path    string
+	Message string
+}
+
+type ConfigProblems []ConfigProblem
+
+func (p ConfigProblems) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d config problem(s):", len(p))
+	for _, problem := range p {
+		fmt.Fprintf(&b, "\n  - %s: %s", problem.Path, problem.Message)
+	}
+	return b.String()
+}
+
+func (p *ConfigProblems) add(path, format string, args ...any) {
+	*p = append(*p, ConfigProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// ReadConfig decodes the config file strictly and applies the environment
+// (see applyEnvironment). Unknown fields, values of the wrong type and
+// unresolvable secrets are returned as problems rather than aborting, so
+// that they can be reported together with the ones found by Validate. The
+// error is only set when the file cannot be read or is not JSON at all.
+func ReadConfig(path string) (*Config, ConfigProblems, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config file error: %w", err)
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := lineAndColumn(data, syntaxErr.Offset)
+			return nil, nil, fmt.Errorf("config parse error at line %d, column %d: %w", line, column, err)
+		}
+		return nil, nil, fmt.Errorf("config parse error: %w", err)
+	}
+
+	var problems ConfigProblems
+	checkUnknownFields(raw, reflect.TypeOf(Config{}), "", &problems)
+
+	var config Config
+	if err := decodeFields(data, reflect.ValueOf(&config).Elem(), "", &problems); err != nil {
+		return nil, nil, fmt.Errorf("config parse error: %w", err)
+	}
+
+	problems = append(problems, applyEnvironment(&config)...)
+	return &config, problems, nil
+}
+
+// Validate checks the decoded values. knownPlatforms lists the platforms
+// that have an adapter.
+func (c *Config) Validate(knownPlatforms []string) ConfigProblems {
+	var problems ConfigProblems
+
+	validateNotifiers(c, &problems)
+	validateDigest(c.Digest, "digest", &problems)
+
+	if c.Database.Path == "" {
+		problems.add("database.path", "required")
+	} else if dir := filepath.Dir(c.Database.Path); dir != "." {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			problems.add("database.path", "directory %s does not exist", dir)
+		}
+	}
+
+	checkInterval(&problems, "watch.interval", c.Watch.Interval)
+	checkDuration(&problems, "watch.jitter", c.Watch.Jitter)
+
+	if c.Concurrency < 0 {
+		problems.add("concurrency", "must not be negative")
+	}
+
+	known := make(map[string]bool)
+	for _, name := range knownPlatforms {
+		known[name] = true
+	}
+
+	names := make([]string, 0, len(c.Platforms))
+	for name := range c.Platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		platform := c.Platforms[name]
+		path := "platforms." + name
+
+		if !known[name] {
+			problems.add(path, "unknown platform%s (available: %s)",
+				suggestion(name, knownPlatforms), strings.Join(knownPlatforms, ", "))
+			continue
+		}
+
+		if platform.URL == "" {
+			if platform.Monitor {
+				problems.add(path+".url", "required when monitor is true")
+			}
+		} else if u, err := url.Parse(platform.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems.add(path+".url", "not an http(s) URL")
+		}
+
+		checkInterval(&problems, path+".interval", platform.Interval)
+	}
+
+	return problems
+}
+
+func checkDuration(problems *ConfigProblems, path, value string) {
+	if value == "" {
+		return
+	}
+	if duration, err := time.ParseDuration(value); err != nil || duration < 0 {
+		problems.add(path, "not a valid duration %q (e.g. \"30m\", \"1h\")", value)
+	}
+}
+
+// checkInterval also rejects intervals so short that watch mode would
+// hammer the platform feeds.
+func checkInterval(problems *ConfigProblems, path, value string) {
+	if duration, err := time.ParseDuration(value); err == nil && duration < MinWatchInterval {
+		problems.add(path, "must be at least 1m")
+		return
+	}
+	checkDuration(problems, path, value)
+}
+
+func checkUnknownFields(raw any, t reflect.Type, path string, problems *ConfigProblems) {
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return
+		}
+
+		fields := make(map[string]reflect.Type)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = field.Type
+			names = append(names, name)
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fieldType, exists := fields[key]
+			if !exists {
+				problems.add(joinPath(path, key), "unknown field%s", suggestion(key, names))
+				continue
+			}
+			checkUnknownFields(object[key], fieldType, joinPath(path, key), problems)
+		}
+
+	case reflect.Map:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return
+		}
+		for key, value := range object {
+			checkUnknownFields(value, t.Elem(), joinPath(path, key), problems)
+		}
+
+	case reflect.Pointer:
+		checkUnknownFields(raw, t.Elem(), path, problems)
+
+	case reflect.Slice:
+		items, ok := raw.([]any)
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			checkUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	}
+}
+
+// decodeFields decodes data into v one field at a time, so that every value
+// of the wrong type is reported rather than only the first. Such values are
+// left at their zero value.
+func decodeFields(data []byte, v reflect.Value, path string, problems *ConfigProblems) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			break
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			// Keys are matched case-insensitively, like encoding/json does.
+			value, exists := object[name]
+			for key := range object {
+				if !exists && strings.EqualFold(key, name) {
+					value, exists = object[key], true
+				}
+			}
+			if !exists {
+				applyDefaults(v.Field(i), field.Tag)
+				continue
+			}
+			if err := decodeFields(value, v.Field(i), joinPath(path, name), problems); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			break
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(object)))
+		for _, key := range keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeFields(object[key], elem, joinPath(path, key), problems); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+		return nil
+
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			break
+		}
+
+		v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		for i, item := range items {
+			if err := decodeFields(item, v.Index(i), fmt.Sprintf("%s[%d]", path, i), problems); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		return decodeFields(data, v.Elem(), path, problems)
+	}
+
+	err := json.Unmarshal(data, v.Addr().Interface())
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		problems.add(path, "expected %s, got %s", v.Type(), typeErr.Value)
+		return nil
+	}
+	return err
+}
+
+// applyDefaults sets fields missing from the config to their default tag.
+// Notification flags added after a release default to on, so that existing
+// configs keep getting the alerts they got before.
+func applyDefaults(v reflect.Value, tag reflect.StructTag) {
+	if value := tag.Get("default"); value != "" {
+		setFromString(v, value)
+		return
+	}
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			applyDefaults(v.Field(i), v.Type().Field(i).Tag)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func suggestion(name string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	before := data[:min(int(offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
@@ -9,39 +10,11 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"time"
-)
-
-type Config struct {
-	DiscordWebhook string              `json:"DiscordWebhook"`
-	Telegram       TelegramConfig      `json:"telegram"`
-	Database       DatabaseConfig      `json:"database"`
-	Platforms      map[string]Platform `json:"platforms"`
-}
-
-type TelegramConfig struct {
-	BotToken string `json:"bot_token"`
-	ChatID   string `json:"chat_id"`
-}
-
-type DatabaseConfig struct {
-	Path string `json:"path"`
-}
 
-type Platform struct {
-	URL           string        `json:"url"`
-	Monitor       bool          `json:"monitor"`
-	Notifications Notifications `json:"notifications"`
-}
-
-type Notifications struct {
-	NewProgram     bool `json:"new_program"`
-	RemovedProgram bool `json:"removed_program"`
-	NewScope       bool `json:"new_scope"`
-	RemovedScope   bool `json:"removed_scope"`
-	ChangedScope   bool `json:"changed_scope"`
-	NewType        bool `json:"new_type"`
-}
+	_ "modernc.org/sqlite"
+)
 
 type Program struct {
 	ID         int    `json:"id"`
@@ -57,6 +30,10 @@ type Program struct {
 	Reward     string `json:"reward"`
 	CreatedAt  string `json:"created_at"`
 	UpdatedAt  string `json:"updated_at"`
+	// LastChangedAt is when a change to the program was last detected and
+	// LastSeenAt when it was last present in its platform's feed.
+	LastChangedAt string `json:"last_changed_at"`
+	LastSeenAt    string `json:"last_seen_at"`
 }
 
 type Reward struct {
@@ -65,103 +42,241 @@ type Reward struct {
 }
 
 type Alert struct {
-	Program      *Program
-	IsNew        bool
-	IsRemoved    bool
-	NewScope     []string
-	RemovedScope []string
-	ChangedScope []ScopeChange
-	NewType      string
-	Reward       *Reward
+	Program           *Program          `json:"program"`
+	IsNew             bool              `json:"is_new"`
+	IsRemoved         bool              `json:"is_removed"`
+	NewScope          []string          `json:"new_scope,omitempty"`
+	RemovedScope      []string          `json:"removed_scope,omitempty"`
+	ChangedScope      []ScopeChange     `json:"changed_scope,omitempty"`
+	ChangedAttributes []AttributeChange `json:"changed_attributes,omitempty"`
+	NewOutOfScope     []string          `json:"new_out_of_scope,omitempty"`
+	RemovedOutOfScope []string          `json:"removed_out_of_scope,omitempty"`
+	PreviousName      string            `json:"previous_name,omitempty"`
+	PreviousURL       string            `json:"previous_url,omitempty"`
+	NewType           string            `json:"new_type,omitempty"`
+	PreviousType      string            `json:"previous_type,omitempty"`
+	Reward            *Reward           `json:"reward,omitempty"`
+	PreviousReward    *Reward           `json:"previous_reward,omitempty"`
 }
 
-type ScopeChange struct {
-	Old string `json:"old"`
-	New string `json:"new"`
+// DBTX is implemented by both *sql.DB and *sql.Tx, so the functions below
+// can run inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
-func InitDatabase(db *sql.DB) {
-	query := `
-	CREATE TABLE IF NOT EXISTS programs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		url TEXT NOT NULL,
-		type TEXT NOT NULL,
-		key TEXT UNIQUE NOT NULL,
-		platform TEXT NOT NULL,
-		logo TEXT,
-		scope TEXT DEFAULT '{}',
-		in_scope TEXT DEFAULT '[]',
-		out_of_scope TEXT DEFAULT '[]',
-		reward TEXT DEFAULT '{}',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_programs_key ON programs(key);
-	CREATE INDEX IF NOT EXISTS idx_programs_platform ON programs(platform);
-	`
-
-	_, err := db.Exec(query)
-	if err != nil {
-		log.Fatal("  Database initialization error:", err)
-	}
-	log.Println("💾 Database initialized successfully")
+// OpenDatabase sets a busy timeout and WAL journaling so readers don't block
+// the single writer (see DBWriter).
+func OpenDatabase(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 }
 
-func IsFirstRun(db *sql.DB) bool {
+// IsFirstRun reports whether nothing is stored yet for platform, or at all
+// when platform is empty.
+func IsFirstRun(ctx context.Context, db DBTX, platform string) bool {
+	query := "SELECT COUNT(*) FROM programs"
+	var args []any
+	if platform != "" {
+		query += " WHERE platform = ?"
+		args = append(args, platform)
+	}
+
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM programs").Scan(&count)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count)
 	if err != nil {
 		return true
 	}
 	return count == 0
 }
 
-func SaveProgram(db *sql.DB, program *Program) error {
-	query := `
-	INSERT OR REPLACE INTO programs 
-	(name, url, type, key, platform, logo, scope, in_scope, out_of_scope, reward, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`
+// SaveProgram records a change to an existing program or inserts a new one.
+// Updates keep the row's id and created_at (first seen). It updates first
+// rather than upserting, since an upsert would use up an id every time.
+func SaveProgram(ctx context.Context, db DBTX, program *Program) error {
+	result, err := db.ExecContext(ctx, `
+	UPDATE programs SET
+		name = ?, url = ?, type = ?, platform = ?, logo = ?, scope = ?, in_scope = ?,
+		out_of_scope = ?, reward = ?,
+		updated_at = CURRENT_TIMESTAMP,
+		last_changed_at = CURRENT_TIMESTAMP,
+		last_seen_at = CURRENT_TIMESTAMP
+	WHERE key = ?
+	`,
+		program.Name, program.URL, program.Type, program.Platform, program.Logo,
+		program.Scope, program.InScope, program.OutOfScope, program.Reward, program.Key,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return err
+	}
 
-	_, err := db.Exec(query,
+	_, err = db.ExecContext(ctx, `
+	INSERT INTO programs
+	(name, url, type, key, platform, logo, scope, in_scope, out_of_scope, reward,
+		updated_at, last_changed_at, last_seen_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`,
 		program.Name, program.URL, program.Type, program.Key, program.Platform,
 		program.Logo, program.Scope, program.InScope, program.OutOfScope, program.Reward,
 	)
 	return err
 }
 
-func GetProgram(db *sql.DB, key string) (*Program, error) {
+// SaveBaseline stores the scope and out-of-scope assets of a program in the
+// current format without counting it as a change.
+func SaveBaseline(ctx context.Context, db DBTX, key, scope, outOfScope string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE programs SET scope = ?, out_of_scope = ?, updated_at = CURRENT_TIMESTAMP WHERE key = ?",
+		scope, outOfScope, key)
+	return err
+}
+
+// TouchPrograms marks the programs as present in the latest feed.
+func TouchPrograms(ctx context.Context, db DBTX, keys []string) error {
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), 500)]
+		keys = keys[len(batch):]
+
+		args := make([]any, len(batch))
+		for i, key := range batch {
+			args[i] = key
+		}
+		_, err := db.ExecContext(ctx,
+			"UPDATE programs SET last_seen_at = CURRENT_TIMESTAMP WHERE key IN ("+placeholders(len(batch))+")", args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const programColumns = `id, name, url, type, key, platform, logo, scope, in_scope, out_of_scope, reward,
+	created_at, updated_at, last_changed_at, last_seen_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProgram(row rowScanner) (*Program, error) {
 	program := &Program{}
-	err := db.QueryRow(`
-		SELECT id, name, url, type, key, platform, logo, scope, in_scope, out_of_scope, reward, created_at, updated_at
-		FROM programs WHERE key = ?
-	`, key).Scan(
+	err := row.Scan(
 		&program.ID, &program.Name, &program.URL, &program.Type, &program.Key, &program.Platform,
 		&program.Logo, &program.Scope, &program.InScope, &program.OutOfScope, &program.Reward,
-		&program.CreatedAt, &program.UpdatedAt,
+		&program.CreatedAt, &program.UpdatedAt, &program.LastChangedAt, &program.LastSeenAt,
 	)
-
 	if err != nil {
 		return nil, err
 	}
 	return program, nil
 }
 
-func DeleteProgram(db *sql.DB, key string) error {
-	_, err := db.Exec("DELETE FROM programs WHERE key = ?", key)
+func GetProgram(ctx context.Context, db DBTX, key string) (*Program, error) {
+	return scanProgram(db.QueryRowContext(ctx,
+		"SELECT "+programColumns+" FROM programs WHERE key = ?", key))
+}
+
+// FindProgram looks a program up by key, then by handle (the key without its
+// platform prefix) or case-insensitive name.
+func FindProgram(ctx context.Context, db DBTX, ref string) (*Program, error) {
+	program, err := GetProgram(ctx, db, ref)
+	if err != sql.ErrNoRows {
+		return program, err
+	}
+	return scanProgram(db.QueryRowContext(ctx,
+		"SELECT "+programColumns+` FROM programs
+		WHERE substr(key, instr(key, ':') + 1) = lower(?) OR name = ? COLLATE NOCASE
+		ORDER BY platform LIMIT 1`, ref, ref))
+}
+
+func ListPrograms(ctx context.Context, db DBTX, platforms []string) ([]*Program, error) {
+	return queryPrograms(ctx, db, "", nil, platforms)
+}
+
+// SearchPrograms matches query against program names, URLs and scope.
+func SearchPrograms(ctx context.Context, db DBTX, query string, platforms []string) ([]*Program, error) {
+	pattern := "%" + query + "%"
+	return queryPrograms(ctx, db, "(name LIKE ? OR url LIKE ? OR scope LIKE ?)",
+		[]any{pattern, pattern, pattern}, platforms)
+}
+
+func queryPrograms(ctx context.Context, db DBTX, where string, args []any, platforms []string) ([]*Program, error) {
+	var conditions []string
+	if where != "" {
+		conditions = append(conditions, where)
+	}
+	if len(platforms) > 0 {
+		conditions = append(conditions, "platform IN ("+placeholders(len(platforms))+")")
+		for _, platform := range platforms {
+			args = append(args, platform)
+		}
+	}
+
+	query := "SELECT " + programColumns + " FROM programs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY platform, name COLLATE NOCASE"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var programs []*Program
+	for rows.Next() {
+		program, err := scanProgram(rows)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+	return programs, rows.Err()
+}
+
+func DeleteProgram(ctx context.Context, db DBTX, key string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM programs WHERE key = ?", key)
+	return err
+}
+
+// FindLegacyProgram looks up a program of platform that is still stored
+// under a name|url key (see GenerateProgramKey) by its URL, or else by its
+// name. Either only counts when it identifies a single legacy program.
+func FindLegacyProgram(ctx context.Context, db DBTX, platform, name, url string) (*Program, error) {
+	const legacy = "key NOT LIKE '%:%'"
+	for _, match := range []struct{ where, value string }{{"url = ?", url}, {"name = ?", name}} {
+		programs, err := queryPrograms(ctx, db, legacy+" AND "+match.where, []any{match.value}, []string{platform})
+		if err != nil {
+			return nil, err
+		}
+		if len(programs) == 1 {
+			return programs[0], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// RekeyProgram moves a program and its history to a new key.
+func RekeyProgram(ctx context.Context, db DBTX, oldKey, newKey string) error {
+	if _, err := db.ExecContext(ctx, "UPDATE programs SET key = ? WHERE key = ?", newKey, oldKey); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, "UPDATE program_events SET program_key = ? WHERE program_key = ?", newKey, oldKey)
 	return err
 }
 
-func GetAllProgramKeys(db *sql.DB, platform string) ([]string, error) {
+func GetAllProgramKeys(ctx context.Context, db DBTX, platform string) ([]string, error) {
 	var keys []string
 	query := "SELECT key FROM programs"
 	if platform != "" {
 		query += " WHERE platform = ?"
 	}
 
-	rows, err := db.Query(query, platform)
+	rows, err := db.QueryContext(ctx, query, platform)
 	if err != nil {
 		return nil, err
 	}
@@ -178,30 +293,45 @@ func GetAllProgramKeys(db *sql.DB, platform string) ([]string, error) {
 	return keys, nil
 }
 
-func FetchData(url string) ([]byte, error) {
+func FetchData(ctx context.Context, url string) ([]byte, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
 	var lastErr error
 	for i := 0; i < 3; i++ {
-		resp, err := client.Get(url)
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			lastErr = err
 			log.Printf(" 🍀 Attempt %d failed for %s: %v", i+1, url, err)
-			time.Sleep(2 * time.Second)
 			continue
 		}
-		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
 			lastErr = fmt.Errorf("bad status: %s", resp.Status)
 			log.Printf(" 🍀 Attempt %d: %s returned %s", i+1, url, resp.Status)
-			time.Sleep(2 * time.Second)
 			continue
 		}
 
 		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			lastErr = err
 			continue
@@ -214,26 +344,19 @@ func FetchData(url string) ([]byte, error) {
 	return nil, fmt.Errorf("failed to fetch %s after 3 attempts: %v", url, lastErr)
 }
 
-func GenerateProgramKey(name, url string) string {
-	hash := md5.Sum([]byte(fmt.Sprintf("%s|%s", name, url)))
-	return hex.EncodeToString(hash[:])
-}
-
-func SerializeScope(scope map[string]string) (string, error) {
-	if scope == nil {
-		return "{}", nil
+// ProgramKey derives the key from the platform's own identifier for the
+// program, which survives renames and URL changes. Programs without one
+// fall back to GenerateProgramKey.
+func ProgramKey(platform, handle, name, url string) string {
+	if handle == "" {
+		return GenerateProgramKey(name, url)
 	}
-	data, err := json.Marshal(scope)
-	return string(data), err
+	return platform + ":" + strings.ToLower(handle)
 }
 
-func DeserializeScope(scopeStr string) (map[string]string, error) {
-	scope := make(map[string]string)
-	if scopeStr == "" {
-		return scope, nil
-	}
-	err := json.Unmarshal([]byte(scopeStr), &scope)
-	return scope, err
+func GenerateProgramKey(name, url string) string {
+	hash := md5.Sum([]byte(fmt.Sprintf("%s|%s", name, url)))
+	return hex.EncodeToString(hash[:])
 }
 
 func SerializeStringArray(arr []string) (string, error) {
@@ -267,34 +390,6 @@ func DeserializeReward(rewardStr string) (Reward, error) {
 	return reward, err
 }
 
-func CompareScopes(oldScope, newScope map[string]string) ([]string, []string, []ScopeChange) {
-	var newScopes, removedScopes []string
-	var changedScopes []ScopeChange
-
-	for id, newValue := range newScope {
-		if _, exists := oldScope[id]; !exists {
-			newScopes = append(newScopes, newValue)
-		}
-	}
-
-	for id, oldValue := range oldScope {
-		if _, exists := newScope[id]; !exists {
-			removedScopes = append(removedScopes, oldValue)
-		}
-	}
-
-	for id, newValue := range newScope {
-		if oldValue, exists := oldScope[id]; exists && oldValue != newValue {
-			changedScopes = append(changedScopes, ScopeChange{
-				Old: oldValue,
-				New: newValue,
-			})
-		}
-	}
-
-	return newScopes, removedScopes, changedScopes
-}
-
 func FindNewItems(newList, oldList []string) []string {
 	var newItems []string
 	for _, item := range newList {
@@ -324,21 +419,65 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func ShouldSendNotification(isNew bool, alert *Alert, platformConfig Platform, firstRun bool) bool {
+// FilterAlert strips the parts of alert that the platform's notification
+// flags disable. It returns nil when nothing is left to send, and always on
+// the platform's first run, which only stores the baseline.
+func FilterAlert(alert *Alert, notifications Notifications, firstRun bool) *Alert {
 	if firstRun {
-		return isNew && platformConfig.Notifications.NewProgram
+		return nil
 	}
 
 	if alert.IsRemoved {
-		return platformConfig.Notifications.RemovedProgram
+		if !notifications.RemovedProgram {
+			return nil
+		}
+		return alert
 	}
 
-	if isNew {
-		return platformConfig.Notifications.NewProgram
+	if alert.IsNew {
+		if !notifications.NewProgram {
+			return nil
+		}
+		return alert
 	}
 
-	hasChanges := len(alert.NewScope) > 0 || len(alert.RemovedScope) > 0 ||
-		len(alert.ChangedScope) > 0 || alert.NewType != "" || alert.Reward != nil
+	filtered := &Alert{Program: alert.Program}
+	if notifications.NewScope {
+		filtered.NewScope = alert.NewScope
+	}
+	if notifications.RemovedScope {
+		filtered.RemovedScope = alert.RemovedScope
+	}
+	if notifications.ChangedScope {
+		filtered.ChangedScope = alert.ChangedScope
+	}
+	if notifications.AttributeChange {
+		filtered.ChangedAttributes = alert.ChangedAttributes
+	}
+	if notifications.OutOfScope {
+		filtered.NewOutOfScope = alert.NewOutOfScope
+		filtered.RemovedOutOfScope = alert.RemovedOutOfScope
+	}
+	if notifications.RenamedProgram {
+		filtered.PreviousName = alert.PreviousName
+		filtered.PreviousURL = alert.PreviousURL
+	}
+	if notifications.NewType {
+		filtered.NewType = alert.NewType
+		filtered.PreviousType = alert.PreviousType
+	}
+	if notifications.RewardChange {
+		filtered.Reward = alert.Reward
+		filtered.PreviousReward = alert.PreviousReward
+	}
 
-	return hasChanges
+	hasChanges := len(filtered.NewScope) > 0 || len(filtered.RemovedScope) > 0 ||
+		len(filtered.ChangedScope) > 0 || len(filtered.ChangedAttributes) > 0 ||
+		len(filtered.NewOutOfScope) > 0 || len(filtered.RemovedOutOfScope) > 0 ||
+		filtered.PreviousName != "" || filtered.PreviousURL != "" ||
+		filtered.NewType != "" || filtered.Reward != nil
+	if !hasChanges {
+		return nil
+	}
+	return filtered
 }
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DigestOff    = "off"
+	DigestRun    = "run"
+	DigestWindow = "window"
+)
+
+// DigestConfig decides whether a notifier gets one message per alert or a
+// summary. With mode "run" the alerts of each delivery are combined; with
+// "window" they are held until the oldest has waited Window. Otherwise a
+// delivery of more than Threshold alerts (if set) is combined anyway.
+type DigestConfig struct {
+	Mode      string `json:"mode"`
+	Window    string `json:"window"`
+	Threshold int    `json:"threshold"`
+}
+
+// DigestFor returns the digest settings of the named notifier: its own, or
+// the top-level ones.
+func DigestFor(config *Config, notifier string) DigestConfig {
+	for _, notifierConfig := range NotifierConfigs(config) {
+		if notifierConfig.Name == notifier && notifierConfig.Digest != nil {
+			return *notifierConfig.Digest
+		}
+	}
+	return config.Digest
+}
+
+func validateDigest(digest DigestConfig, path string, problems *ConfigProblems) {
+	switch digest.Mode {
+	case "", DigestOff, DigestRun:
+		if digest.Window != "" {
+			problems.add(path+".window", "only used when mode is %q", DigestWindow)
+		}
+	case DigestWindow:
+		if digest.Window == "" {
+			problems.add(path+".window", "required when mode is %q", DigestWindow)
+		} else {
+			checkDuration(problems, path+".window", digest.Window)
+		}
+	default:
+		problems.add(path+".mode", "must be %q, %q or %q", DigestOff, DigestRun, DigestWindow)
+	}
+	if digest.Threshold < 0 {
+		problems.add(path+".threshold", "must not be negative")
+	}
+}
+
+// combines reports whether a delivery of count alerts is sent as a digest.
+func (d DigestConfig) combines(count int) bool {
+	switch d.Mode {
+	case DigestRun, DigestWindow:
+		return count > 1
+	}
+	return d.Threshold > 0 && count > d.Threshold
+}
+
+// window is how long alerts are held, or zero if they are sent right away.
+func (d DigestConfig) window() time.Duration {
+	if d.Mode != DigestWindow {
+		return 0
+	}
+	window, _ := time.ParseDuration(d.Window)
+	return window
+}
+
+// RenderDigest summarizes rendered alerts in one message: new and removed
+// programs, then the updates grouped by platform.
+func RenderDigest(messages []*Message) *Message {
+	digest := &Message{
+		Kind:   MessageDigest,
+		Icon:   "📰",
+		Title:  fmt.Sprintf("PewPew Digest: %d alerts", len(messages)),
+		Digest: messages,
+	}
+
+	var added, removed []string
+	updates := make(map[string][]string)
+	platforms := make(map[string]bool)
+
+	for _, message := range messages {
+		program := message.Program
+		platform := strings.Title(program.Platform)
+		platforms[platform] = true
+
+		switch message.Kind {
+		case MessageNew:
+			added = append(added, fmt.Sprintf("%s (%s, %s)", program.Name, platform, program.Type))
+		case MessageRemoved:
+			removed = append(removed, fmt.Sprintf("%s (%s)", program.Name, platform))
+		default:
+			line := program.Name
+			if summary := summarizeAlert(message.Alert); summary != "" {
+				line += ": " + summary
+			}
+			updates[platform] = append(updates[platform], line)
+		}
+	}
+
+	digest.items("🎉", "New Programs", added)
+	digest.items("🗑️", "Removed Programs", removed)
+
+	names := make([]string, 0, len(platforms))
+	for platform := range platforms {
+		names = append(names, platform)
+	}
+	sort.Strings(names)
+	for _, platform := range names {
+		digest.items("📝", platform+" Updates", updates[platform])
+	}
+
+	digest.Summary = fmt.Sprintf("%d new, %d removed and %d updated program(s) on %s",
+		len(added), len(removed), len(messages)-len(added)-len(removed), strings.Join(names, ", "))
+	return digest
+}
+
+// summarizeAlert describes an update alert in a few words, e.g. "+3 scope,
+// renamed from Acme Inc".
+func summarizeAlert(alert *Alert) string {
+	if alert == nil {
+		return ""
+	}
+
+	var parts []string
+	count := func(n int, format string) {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf(format, n))
+		}
+	}
+	count(len(alert.NewScope), "+%d scope")
+	count(len(alert.RemovedScope), "-%d scope")
+	count(len(alert.ChangedScope), "%d scope changed")
+	count(len(alert.ChangedAttributes), "%d attribute(s) changed")
+	count(len(alert.NewOutOfScope), "%d out of scope")
+	count(len(alert.RemovedOutOfScope), "%d back in scope")
+	if alert.PreviousName != "" {
+		parts = append(parts, "renamed from "+alert.PreviousName)
+	}
+	if alert.PreviousURL != "" {
+		parts = append(parts, "moved")
+	}
+	if alert.NewType != "" {
+		parts = append(parts, "type → "+alert.NewType)
+	}
+	if alert.Reward != nil {
+		parts = append(parts, "bounty "+describeRewardChange(alert))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DiscordWebhook struct {
+	Username  string         `json:"username"`
+	AvatarURL string         `json:"avatar_url"`
+	Embeds    []DiscordEmbed `json:"embeds"`
+}
+
+type DiscordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Thumbnail   DiscordImage   `json:"thumbnail"`
+	Image       DiscordImage   `json:"image"`
+	Fields      []DiscordField `json:"fields"`
+	Footer      DiscordFooter  `json:"footer"`
+	Timestamp   string         `json:"timestamp"`
+	URL         string         `json:"url"`
+}
+
+type DiscordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type DiscordFooter struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url"`
+}
+
+type DiscordImage struct {
+	URL string `json:"url"`
+}
+
+var discordWebhookPath = regexp.MustCompile(`^/api/webhooks/\d+/[\w-]+$`)
+
+func init() {
+	RegisterNotifier("discord", NotifierType{
+		Fields: []string{"webhook_url"},
+		Validate: func(config NotifierConfig, problems *ConfigProblems) {
+			u, err := url.Parse(config.WebhookURL)
+			if config.WebhookURL == "" {
+				problems.add("webhook_url", "required")
+			} else if err != nil || u.Scheme != "https" || !isDiscordHost(u.Host) || !discordWebhookPath.MatchString(u.Path) {
+				problems.add("webhook_url", "not a Discord webhook URL (expected https://discord.com/api/webhooks/<id>/<token>)")
+			}
+		},
+		New: func(config NotifierConfig) Notifier {
+			return &discordNotifier{name: config.Name, webhookURL: config.WebhookURL}
+		},
+	})
+}
+
+func isDiscordHost(host string) bool {
+	switch host {
+	case "discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com":
+		return true
+	}
+	return false
+}
+
+type discordNotifier struct {
+	name       string
+	webhookURL string
+}
+
+func (n *discordNotifier) Name() string {
+	return n.name
+}
+
+func (n *discordNotifier) Send(ctx context.Context, message *Message) error {
+	webhook := &DiscordWebhook{
+		Username:  "🔍 PewPew Watcher",
+		AvatarURL: "https://github.com/M-thefl.png",
+	}
+	switch message.Kind {
+	case MessageStartup:
+		webhook.Username = "🔍 PewPew Watcher 🚀"
+		webhook.Embeds = []DiscordEmbed{createDiscordStartupEmbed(message)}
+	case MessageDigest:
+		webhook.Embeds = []DiscordEmbed{createDiscordDigestEmbed(message)}
+	default:
+		webhook.Embeds = []DiscordEmbed{createDiscordEmbed(message)}
+	}
+	return sendWebhook(ctx, n.webhookURL, webhook)
+}
+
+func createDiscordEmbed(message *Message) DiscordEmbed {
+	program := message.Program
+	programImage := program.Logo
+	if programImage == "" {
+		programImage = getPlatformLogo(program.Platform)
+	}
+
+	embed := DiscordEmbed{
+		Title: message.Icon + " " + message.Title,
+		Color: message.Color(),
+		Thumbnail: DiscordImage{
+			URL: getPlatformLogo(program.Platform),
+		},
+		Image: DiscordImage{
+			URL: programImage,
+		},
+		Footer: DiscordFooter{
+			Text: " 🍀 • GitHub: https://github.com/M-thefl",
+			// IconURL: "https://github.com/M-thefl.png",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+		URL:       program.URL,
+	}
+
+	platform := strings.Title(program.Platform)
+	switch message.Kind {
+	case MessageRemoved:
+		embed.Description = fmt.Sprintf("**%s** has been removed from **%s**\n\n💔 We'll miss this one!",
+			program.Name, platform)
+	case MessageNew:
+		embed.Description = fmt.Sprintf("**%s** just launched on **%s**!\n\n🔗 [View Program](%s)\n📝 Type: `%s`\n\n🚀 Happy hunting!",
+			program.Name, platform, program.URL, program.Type)
+	default:
+		embed.Description = fmt.Sprintf("**%s** has been updated on **%s**\n\n🔗 [View Changes](%s)\n\n👀 Check what's new!",
+			program.Name, platform, program.URL)
+	}
+
+	for _, section := range message.Sections {
+		if section.Items != nil {
+			embed.Fields = append(embed.Fields, DiscordField{
+				Name:   section.Heading(),
+				Value:  fmt.Sprintf("```%s```", formatScope(section.Items, 5)),
+				Inline: false,
+			})
+			continue
+		}
+		embed.Fields = append(embed.Fields, DiscordField{
+			Name:   section.Heading(),
+			Value:  fmt.Sprintf("`%s`", section.Value),
+			Inline: len(section.Value) <= 40,
+		})
+	}
+
+	embed.Fields = append(embed.Fields, DiscordField{
+		Name:   "🔗 Quick Links",
+		Value:  "[👤 Follow M-thefl](https://github.com/M-thefl)",
+		Inline: false,
+	})
+
+	return embed
+}
+
+func createDiscordStartupEmbed(message *Message) DiscordEmbed {
+	embed := DiscordEmbed{
+		Title:       message.Icon + " " + message.Title,
+		Description: "**Hello Hunter!** 👋\n\nI'm now monitoring your favorite bug bounty platforms for new programs, scope changes, and updates.\n\nStay tuned for real-time alerts! 🔥",
+		Color:       message.Color(),
+		Thumbnail: DiscordImage{
+			URL: "https://github.com/M-thefl.png",
+		},
+		Image: DiscordImage{
+			URL: "https://i.pinimg.com/originals/af/fb/ad/affbadfe492f696f184f9cb10eb148cf.gif",
+		},
+		Footer: DiscordFooter{
+			Text: "Crafted with 🌙 by M-thefl",
+			// IconURL: "https://github.com/M-thefl.png",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	for _, section := range message.Sections {
+		embed.Fields = append(embed.Fields, DiscordField{
+			Name:   section.Heading(),
+			Value:  "• " + strings.Join(section.Items, "\n• "),
+			Inline: true,
+		})
+	}
+
+	embed.Fields = append(embed.Fields, DiscordField{
+		Name:   "🔗 Quick Links",
+		Value:  "[⭐ Star on GitHub](https://github.com/M-thefl/pewpew-watcher)\n[👤 Follow M-thefl](https://github.com/M-thefl)",
+		Inline: false,
+	})
+
+	return embed
+}
+
+func createDiscordDigestEmbed(message *Message) DiscordEmbed {
+	embed := DiscordEmbed{
+		Title:       message.Icon + " " + message.Title,
+		Description: message.Summary,
+		Color:       message.Color(),
+		Footer: DiscordFooter{
+			Text: " 🍀 • GitHub: https://github.com/M-thefl",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	// Discord caps field values at 1024 characters.
+	for _, section := range message.Sections {
+		embed.Fields = append(embed.Fields, DiscordField{
+			Name:   section.Heading(),
+			Value:  fmt.Sprintf("```%s```", shorten(formatScope(section.Items, 10), 1000)),
+			Inline: false,
+		})
+	}
+
+	return embed
+}
+
+func sendWebhook(ctx context.Context, webhookURL string, webhook *DiscordWebhook) error {
+	jsonData, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+
+	resp, err := postJSON(ctx, webhookURL, jsonData)
+	if err != nil {
+		// The webhook URL embeds its token; keep it out of the logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("discord request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		// Discord sends the wait in seconds, possibly fractional.
+		deliveryErr := &DeliveryError{StatusCode: resp.StatusCode}
+		if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+			deliveryErr.RetryAfter = time.Duration(seconds * float64(time.Second))
+		}
+		return deliveryErr
+	}
+
+	return nil
+}
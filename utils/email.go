@@ -0,0 +1,374 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EmailStartTLS    = "starttls"
+	EmailImplicitTLS = "tls"
+	EmailPlain       = "none"
+)
+
+// emailMaxItems caps each list; unlike chat messages, emails have no hard
+// size limit, but a list of hundreds of assets is not read anyway.
+const emailMaxItems = 50
+
+const emailTimeout = 30 * time.Second
+
+var emailDefaultPorts = map[string]int{
+	EmailStartTLS:    587,
+	EmailImplicitTLS: 465,
+	EmailPlain:       25,
+}
+
+func init() {
+	RegisterNotifier("email", NotifierType{
+		Fields: []string{"host", "port", "tls", "username", "password", "from", "to"},
+		Validate: func(config NotifierConfig, problems *ConfigProblems) {
+			if config.Host == "" {
+				problems.add("host", "required")
+			}
+			if config.Port < 0 || config.Port > 65535 {
+				problems.add("port", "not a valid port")
+			}
+			if _, known := emailDefaultPorts[emailTLS(config)]; !known {
+				problems.add("tls", "must be %q, %q or %q", EmailStartTLS, EmailImplicitTLS, EmailPlain)
+			}
+			if (config.Username == "") != (config.Password == "") {
+				problems.add("username", "username and password must be set together")
+			}
+			// net/smtp refuses to send credentials unencrypted to anything but
+			// localhost, so every delivery would fail.
+			if config.Username != "" && emailTLS(config) == EmailPlain && !isLocalhost(config.Host) {
+				problems.add("tls", "%q cannot log in to %s without encryption, use %q or %q",
+					EmailPlain, config.Host, EmailStartTLS, EmailImplicitTLS)
+			}
+
+			if config.From == "" {
+				problems.add("from", "required")
+			} else if _, err := mail.ParseAddress(config.From); err != nil {
+				problems.add("from", "not an email address: %v", err)
+			}
+			if len(config.To) == 0 {
+				problems.add("to", "at least one recipient is required")
+			}
+			for _, to := range config.To {
+				if _, err := mail.ParseAddress(to); err != nil {
+					problems.add("to", "%q is not an email address", to)
+				}
+			}
+		},
+		New: func(config NotifierConfig) Notifier {
+			return &emailNotifier{config: config}
+		},
+	})
+}
+
+func emailTLS(config NotifierConfig) string {
+	if config.TLS == "" {
+		return EmailStartTLS
+	}
+	return config.TLS
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+type emailNotifier struct {
+	config NotifierConfig
+}
+
+func (n *emailNotifier) Name() string {
+	return n.config.Name
+}
+
+func (n *emailNotifier) Send(ctx context.Context, message *Message) error {
+	from, _ := mail.ParseAddress(n.config.From)
+	var recipients []string
+	for _, to := range n.config.To {
+		address, _ := mail.ParseAddress(to)
+		recipients = append(recipients, address.Address)
+	}
+
+	body, err := createEmail(message, n.config.From, n.config.To, time.Now())
+	if err != nil {
+		return err
+	}
+	return n.deliver(ctx, from.Address, recipients, body)
+}
+
+// deliver runs one SMTP session. Implicit TLS is negotiated before the
+// greeting; STARTTLS is required rather than attempted, so credentials are
+// never sent in the clear unless tls is "none".
+func (n *emailNotifier) deliver(ctx context.Context, from string, recipients []string, body []byte) error {
+	mode := emailTLS(n.config)
+	port := n.config.Port
+	if port == 0 {
+		port = emailDefaultPorts[mode]
+	}
+	address := net.JoinHostPort(n.config.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: n.config.Host}
+
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		deadline = time.Now().Add(emailTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if mode == EmailImplicitTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if mode == EmailStartTLS {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return fmt.Errorf("%s does not support STARTTLS", address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if n.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)); err != nil {
+			return emailError(err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return emailError(err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return emailError(err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return emailError(err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return emailError(err)
+	}
+	return client.Quit()
+}
+
+// emailError turns SMTP rejections into a DeliveryError carrying the reply
+// code, like the HTTP notifiers do with their status.
+func emailError(err error) error {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return &DeliveryError{StatusCode: smtpErr.Code, Reason: smtpErr.Msg}
+	}
+	return err
+}
+
+// createEmail renders the message as a multipart/alternative email with a
+// plain-text and an HTML part.
+func createEmail(message *Message, from string, to []string, date time.Time) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	if err := emailTemplate.Execute(&htmlBody, newEmailView(message)); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	headers := []struct{ name, value string }{
+		{"From", from},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", emailSubject(message))},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", emailMessageID(from)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	var head bytes.Buffer
+	for _, header := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", header.name, header.value)
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", formatEmailText(message)},
+		{"text/html; charset=utf-8", htmlBody.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+func emailSubject(message *Message) string {
+	if message.Program == nil {
+		return message.Icon + " " + message.Title
+	}
+	return fmt.Sprintf("%s [%s] %s", message.Icon, strings.Title(message.Program.Platform), message.Title)
+}
+
+func emailMessageID(from string) string {
+	domain := "pewpew-watcher"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if _, host, found := strings.Cut(address.Address, "@"); found {
+			domain = host
+		}
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(random), domain)
+}
+
+func emailDescription(message *Message) string {
+	switch message.Kind {
+	case MessageStartup:
+		return "Hello Hunter! I'm now monitoring your favorite bug bounty platforms for new programs, scope changes, and updates."
+	case MessageDigest:
+		return message.Summary + "."
+	}
+
+	program := message.Program
+	platform := strings.Title(program.Platform)
+	switch message.Kind {
+	case MessageRemoved:
+		return fmt.Sprintf("%s has been removed from %s. We'll miss this one!", program.Name, platform)
+	case MessageNew:
+		return fmt.Sprintf("%s just launched on %s (%s). Happy hunting!", program.Name, platform, program.Type)
+	}
+	return fmt.Sprintf("%s has been updated on %s.", program.Name, platform)
+}
+
+func formatEmailText(message *Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n\n%s\n", message.Icon, message.Title, emailDescription(message))
+	if message.Program != nil {
+		fmt.Fprintf(&b, "%s\n", message.Program.URL)
+	}
+
+	for _, section := range message.Sections {
+		if section.Items == nil {
+			fmt.Fprintf(&b, "\n%s: %s\n", section.Heading(), section.Value)
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", section.Heading())
+		for _, line := range strings.Split(formatScope(section.Items, emailMaxItems), "\n") {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+
+	b.WriteString("\n-- \nPewPew Watcher • https://github.com/M-thefl/pewpew-watcher\n")
+	return b.String()
+}
+
+type emailView struct {
+	Heading     string
+	Description string
+	URL         string
+	Color       string
+	Sections    []emailSection
+}
+
+type emailSection struct {
+	Heading string
+	Value   string
+	Items   []string
+}
+
+func newEmailView(message *Message) emailView {
+	view := emailView{
+		Heading:     message.Icon + " " + message.Title,
+		Description: emailDescription(message),
+		Color:       fmt.Sprintf("#%06X", message.Color()),
+	}
+	if message.Program != nil {
+		view.URL = message.Program.URL
+	}
+	for _, section := range message.Sections {
+		var items []string
+		if section.Items != nil {
+			items = strings.Split(formatScope(section.Items, emailMaxItems), "\n")
+		}
+		view.Sections = append(view.Sections, emailSection{Heading: section.Heading(), Value: section.Value, Items: items})
+	}
+	return view
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f4f4f5;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#18181b;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:640px;margin:0 auto;background:#ffffff;border-left:6px solid {{.Color}};border-radius:4px;">
+<tr><td style="padding:20px 24px;">
+<h2 style="margin:0 0 12px;font-size:20px;">{{.Heading}}</h2>
+<p style="margin:0 0 12px;font-size:14px;line-height:1.5;">{{.Description}}</p>
+{{- if .URL}}
+<p style="margin:0 0 16px;font-size:14px;"><a href="{{.URL}}" style="color:#2563eb;">🔗 View program</a></p>
+{{- end}}
+{{- range .Sections}}
+<h3 style="margin:16px 0 6px;font-size:15px;">{{.Heading}}</h3>
+{{- if .Items}}
+<ul style="margin:0;padding-left:20px;font-family:Menlo,Consolas,monospace;font-size:13px;line-height:1.6;">
+{{- range .Items}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p style="margin:0;font-family:Menlo,Consolas,monospace;font-size:13px;">{{.Value}}</p>
+{{- end}}
+{{- end}}
+</td></tr>
+<tr><td style="padding:12px 24px;border-top:1px solid #e4e4e7;font-size:12px;color:#71717a;">
+🍀 PewPew Watcher • <a href="https://github.com/M-thefl/pewpew-watcher" style="color:#71717a;">GitHub</a>
+</td></tr>
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	EventNew               = "new"
+	EventRemoved           = "removed"
+	EventRenamed           = "renamed"
+	EventMoved             = "moved"
+	EventScopeAdded        = "scope_added"
+	EventScopeRemoved      = "scope_removed"
+	EventScopeChanged      = "scope_changed"
+	EventAttributeChanged  = "attribute_changed"
+	EventOutOfScopeAdded   = "out_of_scope_added"
+	EventOutOfScopeRemoved = "out_of_scope_removed"
+	EventTypeChanged       = "type_changed"
+	EventRewardChanged     = "reward_changed"
+)
+
+// ProgramEvent is a single change to a program. Before and After hold the
+// JSON-encoded values and are empty when not applicable (e.g. Before of a
+// new program).
+type ProgramEvent struct {
+	ID         int64           `json:"id"`
+	ProgramKey string          `json:"program_key"`
+	Platform   string          `json:"platform"`
+	Program    string          `json:"program"`
+	Kind       string          `json:"kind"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// EventQuery filters QueryEvents. Program matches a program key, its handle
+// alone (e.g. "acme" for "hackerone:acme") or, case-insensitively, any name
+// the program has had, including the ones it was renamed from. Zero values
+// match everything.
+type EventQuery struct {
+	Program   string
+	Platforms []string
+	Kinds     []string
+	Since     time.Time
+	Limit     int
+}
+
+// AlertEvents breaks an unfiltered alert down into one event per change.
+// previous is the stored program the alert was diffed against; it is nil
+// for new and removed programs.
+func AlertEvents(alert *Alert, previous *Program) []*ProgramEvent {
+	var events []*ProgramEvent
+	add := func(kind string, before, after any) {
+		event := &ProgramEvent{
+			ProgramKey: alert.Program.Key,
+			Platform:   alert.Program.Platform,
+			Program:    alert.Program.Name,
+			Kind:       kind,
+		}
+		if before != nil {
+			event.Before, _ = json.Marshal(before)
+		}
+		if after != nil {
+			event.After, _ = json.Marshal(after)
+		}
+		events = append(events, event)
+	}
+
+	snapshot := func(program *Program) map[string]any {
+		reward, _ := DeserializeReward(program.Reward)
+		scope, _ := DeserializeScope(program.Scope)
+		return map[string]any{
+			"name":   program.Name,
+			"url":    program.URL,
+			"type":   program.Type,
+			"reward": reward,
+			"scope":  SortedTargets(scope),
+		}
+	}
+
+	if alert.IsNew {
+		add(EventNew, nil, snapshot(alert.Program))
+		return events
+	}
+	if alert.IsRemoved {
+		add(EventRemoved, snapshot(alert.Program), nil)
+		return events
+	}
+
+	if alert.PreviousName != "" {
+		add(EventRenamed, alert.PreviousName, alert.Program.Name)
+	}
+	if alert.PreviousURL != "" {
+		add(EventMoved, alert.PreviousURL, alert.Program.URL)
+	}
+	for _, scope := range alert.NewScope {
+		add(EventScopeAdded, nil, scope)
+	}
+	for _, scope := range alert.RemovedScope {
+		add(EventScopeRemoved, scope, nil)
+	}
+	for _, change := range alert.ChangedScope {
+		add(EventScopeChanged, change.Old, change.New)
+	}
+	for _, change := range alert.ChangedAttributes {
+		add(EventAttributeChanged,
+			map[string]string{"target": change.Target, change.Attribute: change.Old},
+			map[string]string{"target": change.Target, change.Attribute: change.New})
+	}
+	for _, scope := range alert.NewOutOfScope {
+		add(EventOutOfScopeAdded, nil, scope)
+	}
+	for _, scope := range alert.RemovedOutOfScope {
+		add(EventOutOfScopeRemoved, scope, nil)
+	}
+	if alert.NewType != "" {
+		add(EventTypeChanged, previous.Type, alert.NewType)
+	}
+	if alert.Reward != nil {
+		reward, _ := DeserializeReward(previous.Reward)
+		add(EventRewardChanged, reward, alert.Reward)
+	}
+
+	return events
+}
+
+func RecordEvents(ctx context.Context, db DBTX, events []*ProgramEvent) error {
+	for _, event := range events {
+		_, err := db.ExecContext(ctx, `
+		INSERT INTO program_events (program_key, platform, program_name, kind, before, after)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, event.ProgramKey, event.Platform, event.Program, event.Kind, nullJSON(event.Before), nullJSON(event.After))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryEvents returns the matching events, oldest first. With a Limit, it
+// returns the most recent ones.
+func QueryEvents(ctx context.Context, db DBTX, q EventQuery) ([]*ProgramEvent, error) {
+	var conditions []string
+	var args []any
+
+	if q.Program != "" {
+		conditions = append(conditions, `program_key IN (SELECT program_key FROM program_events
+			WHERE program_key = ?
+			OR substr(program_key, instr(program_key, ':') + 1) = lower(?)
+			OR program_name = ? COLLATE NOCASE
+			OR (kind = '`+EventRenamed+`' AND json_extract(before, '$') = ? COLLATE NOCASE))`)
+		args = append(args, q.Program, q.Program, q.Program, q.Program)
+	}
+	if len(q.Platforms) > 0 {
+		conditions = append(conditions, "platform IN ("+placeholders(len(q.Platforms))+")")
+		for _, platform := range q.Platforms {
+			args = append(args, platform)
+		}
+	}
+	if len(q.Kinds) > 0 {
+		conditions = append(conditions, "kind IN ("+placeholders(len(q.Kinds))+")")
+		for _, kind := range q.Kinds {
+			args = append(args, kind)
+		}
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, sqliteTime(q.Since))
+	}
+
+	query := "SELECT id, program_key, platform, program_name, kind, before, after, created_at FROM program_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*ProgramEvent
+	for rows.Next() {
+		event := &ProgramEvent{}
+		var before, after sql.NullString
+		if err := rows.Scan(&event.ID, &event.ProgramKey, &event.Platform, &event.Program,
+			&event.Kind, &before, &after, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			event.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			event.After = json.RawMessage(after.String)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// sqliteTime formats t like CURRENT_TIMESTAMP, so the two compare as text.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
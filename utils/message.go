@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MessageNew     = "new"
+	MessageRemoved = "removed"
+	MessageUpdated = "updated"
+	MessageStartup = "startup"
+	MessageDigest  = "digest"
+)
+
+const (
+	ColorGreen  = 0x00FF00
+	ColorRed    = 0xFF0000
+	ColorBlue   = 0x0099FF
+	ColorOrange = 0xFFA500
+	ColorPurple = 0x9B59B6
+	ColorGold   = 0xFFD700
+)
+
+var PlatformColors = map[string]int{
+	"hackerone": ColorGreen,
+	"bugcrowd":  ColorOrange,
+	"intigriti": ColorPurple,
+	"yeswehack": ColorBlue,
+}
+
+// Message is the channel-independent form of an alert (or of the startup
+// greeting): a headline and the sections that follow it. Notifiers only
+// decide how to lay it out.
+type Message struct {
+	// ID identifies the delivery and stays the same when it is retried. It
+	// is empty for messages sent outside the outbox.
+	ID    string
+	Kind  string
+	Icon  string
+	Title string
+	// Program and Alert are nil for the startup message and digests. Alert
+	// is kept for notifiers that send structured data rather than text.
+	Program  *Program
+	Alert    *Alert
+	Sections []Section
+	// Summary and Digest are only set for digests: a one-line overview and
+	// the messages it combines.
+	Summary string
+	Digest  []*Message
+}
+
+// Section is either a single short Value (a type, a bounty range) or a list
+// of Items, which channels may cut short to fit their size limits.
+type Section struct {
+	Icon  string
+	Title string
+	Value string
+	Items []string
+}
+
+// Heading is the section title with its icon, e.g. "🆕 New Scope".
+func (s Section) Heading() string {
+	return s.Icon + " " + s.Title
+}
+
+func RenderAlert(alert *Alert) *Message {
+	program := alert.Program
+	message := &Message{Program: program, Alert: alert}
+
+	if alert.IsRemoved {
+		message.Kind, message.Icon, message.Title = MessageRemoved, "🗑️", fmt.Sprintf("%s Removed", program.Name)
+	} else if alert.IsNew {
+		message.Kind, message.Icon, message.Title = MessageNew, "🎉", fmt.Sprintf("New Program: %s", program.Name)
+	} else {
+		message.Kind, message.Icon, message.Title = MessageUpdated, "📝", fmt.Sprintf("%s Updated", program.Name)
+	}
+
+	if alert.PreviousName != "" {
+		message.value("🏷️", "Renamed", fmt.Sprintf("%s → %s", alert.PreviousName, program.Name))
+	}
+	if alert.PreviousURL != "" {
+		message.value("🔀", "Moved", fmt.Sprintf("%s → %s", alert.PreviousURL, program.URL))
+	}
+
+	message.items("🆕", "New Scope", alert.NewScope)
+	message.items("", "Removed Scope", alert.RemovedScope)
+
+	changes := make([]string, len(alert.ChangedScope))
+	for i, change := range alert.ChangedScope {
+		changes[i] = fmt.Sprintf("%s → %s", change.Old, change.New)
+	}
+	message.items("✏️", "Changed Scope", changes)
+
+	message.items("🚫", "Moved Out of Scope", alert.NewOutOfScope)
+	message.items("↩️", "No Longer Out of Scope", alert.RemovedOutOfScope)
+
+	attributes := make([]string, len(alert.ChangedAttributes))
+	for i, change := range alert.ChangedAttributes {
+		attributes[i] = describeAttributeChange(change)
+	}
+	message.items("🎚️", "Target Attributes", attributes)
+
+	if alert.NewType != "" {
+		message.value("🔄", "Type Changed", alert.NewType)
+	}
+	if alert.Reward != nil {
+		message.value("💰", "Bounty Update", describeRewardChange(alert))
+	}
+
+	return message
+}
+
+func RenderStartup() *Message {
+	return &Message{
+		Kind:  MessageStartup,
+		Icon:  "🎯",
+		Title: "PewPew Watcher Started Successfully!",
+		Sections: []Section{
+			{Icon: "📊", Title: "Platforms", Items: []string{"HackerOne", "Bugcrowd", "Intigriti", "YesWeHack"}},
+			{Icon: "🔔", Title: "Alerts", Items: []string{"New Programs", "Scope Changes", "Program Removals", "Type Updates"}},
+		},
+	}
+}
+
+// Color is the accent color for channels that support one: red for
+// removals, gold for new programs and the platform color otherwise.
+func (m *Message) Color() int {
+	switch m.Kind {
+	case MessageRemoved:
+		return ColorRed
+	case MessageNew:
+		return ColorGold
+	case MessageStartup:
+		return ColorPurple
+	case MessageDigest:
+		return ColorBlue
+	}
+	return PlatformColors[m.Program.Platform]
+}
+
+func (m *Message) value(icon, title, value string) {
+	m.Sections = append(m.Sections, Section{Icon: icon, Title: title, Value: value})
+}
+
+func (m *Message) items(icon, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	m.Sections = append(m.Sections, Section{Icon: icon, Title: title, Items: items})
+}
+
+// FormatAlertText renders an alert as plain text, e.g. for dry runs.
+func FormatAlertText(alert *Alert) string {
+	var b strings.Builder
+	platform := strings.Title(alert.Program.Platform)
+
+	if alert.IsRemoved {
+		fmt.Fprintf(&b, "🗑️ [%s] Removed: %s\n", platform, alert.Program.Name)
+	} else if alert.IsNew {
+		fmt.Fprintf(&b, "🎉 [%s] New program: %s (%s)\n", platform, alert.Program.Name, alert.Program.Type)
+	} else {
+		fmt.Fprintf(&b, "📝 [%s] Updated: %s\n", platform, alert.Program.Name)
+	}
+	fmt.Fprintf(&b, "   %s\n", alert.Program.URL)
+
+	if alert.PreviousName != "" {
+		fmt.Fprintf(&b, "   renamed from %s\n", alert.PreviousName)
+	}
+	if alert.PreviousURL != "" {
+		fmt.Fprintf(&b, "   moved from %s\n", alert.PreviousURL)
+	}
+
+	for _, scope := range alert.NewScope {
+		fmt.Fprintf(&b, "   + %s\n", scope)
+	}
+	for _, scope := range alert.RemovedScope {
+		fmt.Fprintf(&b, "   - %s\n", scope)
+	}
+	for _, change := range alert.ChangedScope {
+		fmt.Fprintf(&b, "   ~ %s → %s\n", change.Old, change.New)
+	}
+	for _, change := range alert.ChangedAttributes {
+		fmt.Fprintf(&b, "   ~ %s\n", describeAttributeChange(change))
+	}
+	for _, scope := range alert.NewOutOfScope {
+		fmt.Fprintf(&b, "   🚫 %s moved out of scope\n", scope)
+	}
+	for _, scope := range alert.RemovedOutOfScope {
+		fmt.Fprintf(&b, "   ↩️ %s no longer out of scope\n", scope)
+	}
+	if alert.NewType != "" {
+		fmt.Fprintf(&b, "   type → %s\n", alert.NewType)
+	}
+	if alert.Reward != nil {
+		fmt.Fprintf(&b, "   bounty %s\n", describeRewardChange(alert))
+	}
+
+	return b.String()
+}
+
+// formatScope lists at most maxItems entries, one per line.
+func formatScope(scope []string, maxItems int) string {
+	if len(scope) == 0 {
+		return ""
+	}
+
+	items := scope
+	if len(scope) > maxItems {
+		items = scope[:maxItems]
+	}
+
+	result := strings.Join(items, "\n")
+	if len(scope) > maxItems {
+		result += fmt.Sprintf("\n... and %d more", len(scope)-maxItems)
+	}
+
+	return result
+}
+
+func describeAttributeChange(change AttributeChange) string {
+	oldValue, newValue := change.Old, change.New
+	if oldValue == "" {
+		oldValue = "none"
+	}
+	if newValue == "" {
+		newValue = "none"
+	}
+	return fmt.Sprintf("%s: %s %s → %s", change.Target, change.Attribute, shorten(oldValue, 40), shorten(newValue, 40))
+}
+
+// describeRewardChange renders a reward change as "old → new".
+func describeRewardChange(alert *Alert) string {
+	previous := "none"
+	if alert.PreviousReward != nil {
+		previous = formatReward(*alert.PreviousReward)
+	}
+	return fmt.Sprintf("%s → %s", previous, formatReward(*alert.Reward))
+}
+
+func formatReward(reward Reward) string {
+	if reward.Min == "" && reward.Max == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%s - %s", reward.Min, reward.Max)
+}
+
+func shorten(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength]) + "..."
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are applied in order of the number that prefixes their file
+// name. Never edit one that has been released; add a new file instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		prefix, name, _ := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: file name must start with its version number", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SchemaVersion is 0 for a database that has never been migrated.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	if exists, err := tableExists(ctx, db, "schema_version"); err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// PendingMigrations returns the migrations that have not been applied yet.
+// It fails if the database was migrated by a newer version of the binary.
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]Migration, int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, 0, err
+	}
+	current, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	if current > latest {
+		return nil, current, fmt.Errorf("database schema version %d is newer than this binary supports (%d), upgrade pewpew-watcher", current, latest)
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, current, nil
+}
+
+// MigrateDatabase brings the schema at path up to date. Unless the database
+// is new, it is first copied to a backup file next to it.
+func MigrateDatabase(ctx context.Context, db *sql.DB, path string) error {
+	pending, current, err := PendingMigrations(ctx, db)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	if hasPrograms, err := tableExists(ctx, db, "programs"); err != nil {
+		return err
+	} else if hasPrograms {
+		backup := fmt.Sprintf("%s.v%d-%s.bak", path, current, time.Now().Format("20060102-150405"))
+		if _, err := os.Stat(backup); err == nil {
+			return fmt.Errorf("backup %s already exists", backup)
+		}
+		if _, err := db.ExecContext(ctx, "VACUUM INTO ?", backup); err != nil {
+			return fmt.Errorf("backup before migration failed: %w", err)
+		}
+		log.Printf("💾 Backed up database to %s", backup)
+	}
+
+	_, err = db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		if err := applyMigration(ctx, db, migration); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("💾 Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return nil
+}
+
+// CheckSchema fails if the schema is not up to date, without changing it.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	pending, current, err := PendingMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date (version %d, %d pending migration(s)), run `pewpew-watcher db migrate` first",
+			current, len(pending))
+	}
+	return nil
+}
+
+// applyMigration runs a migration in a transaction. Columns that already
+// exist are skipped, since databases created before schema_version may have
+// some of them already.
+func applyMigration(ctx context.Context, db *sql.DB, migration Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range strings.Split(migration.SQL, ";") {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version, name) VALUES (?, ?)",
+		migration.Version, migration.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func tableExists(ctx context.Context, db *sql.DB, table string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists)
+	return exists, err
+}
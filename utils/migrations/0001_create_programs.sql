@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS programs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	url TEXT NOT NULL,
+	type TEXT NOT NULL,
+	key TEXT UNIQUE NOT NULL,
+	platform TEXT NOT NULL,
+	logo TEXT,
+	scope TEXT DEFAULT '{}',
+	in_scope TEXT DEFAULT '[]',
+	out_of_scope TEXT DEFAULT '[]',
+	reward TEXT DEFAULT '{}',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_programs_key ON programs(key);
+CREATE INDEX IF NOT EXISTS idx_programs_platform ON programs(platform);
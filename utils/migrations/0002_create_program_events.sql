@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS program_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	program_key TEXT NOT NULL,
+	platform TEXT NOT NULL,
+	program_name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	before TEXT,
+	after TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_program_events_key ON program_events(program_key);
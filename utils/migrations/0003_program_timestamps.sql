@@ -0,0 +1,5 @@
+ALTER TABLE programs ADD COLUMN last_changed_at DATETIME;
+ALTER TABLE programs ADD COLUMN last_seen_at DATETIME;
+
+UPDATE programs SET last_changed_at = updated_at WHERE last_changed_at IS NULL;
+UPDATE programs SET last_seen_at = updated_at WHERE last_seen_at IS NULL;
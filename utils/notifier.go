@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Notifier delivers rendered messages to one configured channel, e.g. a
+// single Discord webhook or Telegram chat.
+type Notifier interface {
+	// Name identifies the configured instance; the outbox keys deliveries
+	// by it.
+	Name() string
+	Send(ctx context.Context, message *Message) error
+}
+
+// NotifierConfig configures one notifier. It holds the settings of every
+// type; each type only reads (and allows) its own fields.
+type NotifierConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Digest overrides the top-level digest settings for this notifier.
+	Digest *DigestConfig `json:"digest"`
+
+	WebhookURL string `json:"webhook_url" secret:"true"`
+	BotToken   string `json:"bot_token" secret:"true"`
+	ChatID     string `json:"chat_id" secret:"true"`
+
+	URL     string            `json:"url"`
+	Secret  string            `json:"secret" secret:"true"`
+	Headers map[string]string `json:"headers" secret:"true"`
+
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	TLS      string   `json:"tls"`
+	Username string   `json:"username" secret:"true"`
+	Password string   `json:"password" secret:"true"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// NotifierType builds the notifiers of one type. Fields lists the JSON
+// names of the NotifierConfig settings it uses; Validate reports problems
+// by those names.
+type NotifierType struct {
+	Fields   []string
+	Validate func(config NotifierConfig, problems *ConfigProblems)
+	New      func(config NotifierConfig) Notifier
+}
+
+var notifierTypes = make(map[string]NotifierType)
+
+func RegisterNotifier(name string, notifierType NotifierType) {
+	notifierTypes[name] = notifierType
+}
+
+func NotifierTypes() []string {
+	names := make([]string, 0, len(notifierTypes))
+	for name := range notifierTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NotifierConfigs returns the configured notifiers: the notifiers list plus
+// the top-level DiscordWebhook and telegram settings, which act as
+// notifiers named "discord" and "telegram".
+func NotifierConfigs(config *Config) []NotifierConfig {
+	var configs []NotifierConfig
+	if config.DiscordWebhook != "" {
+		configs = append(configs, NotifierConfig{Name: "discord", Type: "discord", WebhookURL: config.DiscordWebhook})
+	}
+	if config.Telegram.BotToken != "" && config.Telegram.ChatID != "" {
+		configs = append(configs, NotifierConfig{
+			Name:     "telegram",
+			Type:     "telegram",
+			BotToken: config.Telegram.BotToken,
+			ChatID:   config.Telegram.ChatID,
+		})
+	}
+	for _, notifier := range config.Notifiers {
+		if notifier.Name == "" {
+			notifier.Name = notifier.Type
+		}
+		configs = append(configs, notifier)
+	}
+	return configs
+}
+
+// BuildNotifiers creates every configured notifier. The config must have
+// been validated.
+func BuildNotifiers(config *Config) []Notifier {
+	var notifiers []Notifier
+	for _, notifierConfig := range NotifierConfigs(config) {
+		if notifierType, exists := notifierTypes[notifierConfig.Type]; exists {
+			notifiers = append(notifiers, notifierType.New(notifierConfig))
+		}
+	}
+	return notifiers
+}
+
+// Notifiers returns the names of the configured notifiers.
+func Notifiers(config *Config) []string {
+	var names []string
+	for _, notifierConfig := range NotifierConfigs(config) {
+		names = append(names, notifierConfig.Name)
+	}
+	return names
+}
+
+// SendAlert sends the alert to every configured notifier right away. Alerts
+// from a sync go through the outbox instead (see DeliverOutbox).
+func SendAlert(ctx context.Context, alert *Alert, config *Config) {
+	message := RenderAlert(alert)
+	for _, notifier := range BuildNotifiers(config) {
+		DeliverMessage(ctx, notifier, message)
+	}
+}
+
+func SendStartupMessage(ctx context.Context, config *Config, firstRun bool) {
+	if !firstRun {
+		return
+	}
+
+	log.Printf("🚀 Sending startup messages...")
+
+	message := RenderStartup()
+	for _, notifier := range BuildNotifiers(config) {
+		DeliverMessage(ctx, notifier, message)
+	}
+}
+
+// DeliverMessage sends the message and logs the outcome.
+func DeliverMessage(ctx context.Context, notifier Notifier, message *Message) error {
+	subject := "startup"
+	if message.Program != nil {
+		subject = "alert for " + message.Program.Name
+	} else if message.Kind == MessageDigest {
+		subject = fmt.Sprintf("digest of %d alerts", len(message.Digest))
+	}
+
+	err := notifier.Send(ctx, message)
+	if err != nil {
+		log.Printf(" 🍀%s %s failed: %v", notifier.Name(), subject, err)
+	} else {
+		log.Printf(" 🍀%s %s sent", notifier.Name(), subject)
+	}
+	return err
+}
+
+func validateNotifiers(c *Config, problems *ConfigProblems) {
+	// The legacy settings are reported under their own paths.
+	legacy := map[string]string{"webhook_url": "DiscordWebhook", "bot_token": "telegram.bot_token", "chat_id": "telegram.chat_id"}
+
+	if c.DiscordWebhook != "" {
+		validateNotifier(NotifierConfig{Type: "discord", WebhookURL: c.DiscordWebhook}, legacy, problems)
+	}
+	if c.Telegram.BotToken != "" || c.Telegram.ChatID != "" {
+		validateNotifier(NotifierConfig{Type: "telegram", BotToken: c.Telegram.BotToken, ChatID: c.Telegram.ChatID}, legacy, problems)
+	}
+
+	names := make(map[string]string)
+	if c.DiscordWebhook != "" {
+		names["discord"] = "DiscordWebhook"
+	}
+	if c.Telegram.BotToken != "" && c.Telegram.ChatID != "" {
+		names["telegram"] = "telegram"
+	}
+
+	known := NotifierTypes()
+	for i, notifierConfig := range c.Notifiers {
+		path := fmt.Sprintf("notifiers[%d]", i)
+
+		name := notifierConfig.Name
+		if name == "" {
+			name = notifierConfig.Type
+		}
+		if other, exists := names[name]; exists {
+			problems.add(path+".name", "%q is already used by %s; give each notifier a unique name", name, other)
+		} else if name != "" {
+			names[name] = path
+		}
+
+		if notifierConfig.Type == "" {
+			problems.add(path+".type", "required (available: %s)", strings.Join(known, ", "))
+			continue
+		}
+		if _, exists := notifierTypes[notifierConfig.Type]; !exists {
+			problems.add(path+".type", "unknown notifier type%s (available: %s)",
+				suggestion(notifierConfig.Type, known), strings.Join(known, ", "))
+			continue
+		}
+
+		if notifierConfig.Digest != nil {
+			validateDigest(*notifierConfig.Digest, path+".digest", problems)
+		}
+
+		fields := make(map[string]string)
+		for _, field := range notifierFields {
+			fields[field] = path + "." + field
+		}
+		validateNotifier(notifierConfig, fields, problems)
+	}
+}
+
+// notifierFields are the type-specific NotifierConfig fields.
+var notifierFields = func() []string {
+	var fields []string
+	t := reflect.TypeOf(NotifierConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "name" && name != "type" && name != "digest" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}()
+
+// validateNotifier checks config against its type, reporting each field
+// under paths[field].
+func validateNotifier(config NotifierConfig, paths map[string]string, problems *ConfigProblems) {
+	notifierType := notifierTypes[config.Type]
+
+	var found ConfigProblems
+	notifierType.Validate(config, &found)
+
+	used := make(map[string]bool)
+	for _, field := range notifierType.Fields {
+		used[field] = true
+	}
+	v := reflect.ValueOf(config)
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if _, typeSpecific := paths[name]; typeSpecific && !used[name] && !v.Field(i).IsZero() {
+			found.add(name, "not used by %s notifiers", config.Type)
+		}
+	}
+
+	for _, problem := range found {
+		if path, exists := paths[problem.Path]; exists {
+			problem.Path = path
+		}
+		*problems = append(*problems, problem)
+	}
+}
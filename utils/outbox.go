@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+const (
+	// MaxDeliveryAttempts is how often an alert is tried before it is moved
+	// to the dead-letter state.
+	MaxDeliveryAttempts = 8
+
+	outboxBaseBackoff = time.Minute
+	outboxMaxBackoff  = 2 * time.Hour
+	outboxKeepSent    = 7 * 24 * time.Hour
+)
+
+// OutboxEntry is the delivery of one alert to one notifier.
+type OutboxEntry struct {
+	ID            int64  `json:"id"`
+	Notifier      string `json:"notifier"`
+	Alert         *Alert `json:"alert"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	CreatedAt     string `json:"created_at"`
+	SentAt        string `json:"sent_at,omitempty"`
+}
+
+// EnqueueAlerts queues every alert once per notifier. It runs in the same
+// transaction as the changes the alerts describe, so an alert is stored if
+// and only if its change is.
+func EnqueueAlerts(ctx context.Context, db DBTX, alerts []*Alert, notifiers []string) error {
+	for _, alert := range alerts {
+		data, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		for _, notifier := range notifiers {
+			_, err := db.ExecContext(ctx,
+				"INSERT INTO outbox (notifier, program_key, alert) VALUES (?, ?, ?)",
+				notifier, alert.Program.Key, string(data))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListOutbox returns entries with the given status (all if empty), oldest
+// first.
+func ListOutbox(ctx context.Context, db DBTX, status string) ([]*OutboxEntry, error) {
+	where, args := "", []any{}
+	if status != "" {
+		where, args = "WHERE status = ?", append(args, status)
+	}
+	return queryOutbox(ctx, db, where+" ORDER BY id", args...)
+}
+
+func dueOutbox(ctx context.Context, db DBTX, now time.Time) ([]*OutboxEntry, error) {
+	return queryOutbox(ctx, db, "WHERE status = ? AND next_attempt_at <= ? ORDER BY id",
+		OutboxPending, sqliteTime(now))
+}
+
+func queryOutbox(ctx context.Context, db DBTX, where string, args ...any) ([]*OutboxEntry, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, notifier, alert, status, attempts, last_error,
+		next_attempt_at, created_at, COALESCE(sent_at, '') FROM outbox `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*OutboxEntry
+	for rows.Next() {
+		entry := &OutboxEntry{}
+		var alert string
+		if err := rows.Scan(&entry.ID, &entry.Notifier, &alert, &entry.Status, &entry.Attempts,
+			&entry.LastError, &entry.NextAttemptAt, &entry.CreatedAt, &entry.SentAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(alert), &entry.Alert); err != nil {
+			return nil, fmt.Errorf("outbox entry %d: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// RetryOutbox puts dead entries back in the queue, either the given ones or,
+// without ids, all of them. It returns how many were requeued.
+func RetryOutbox(ctx context.Context, db DBTX, ids []int64) (int64, error) {
+	query := "UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE status = ?"
+	args := []any{OutboxPending, OutboxDead}
+	if len(ids) > 0 {
+		query += " AND id IN (" + placeholders(len(ids)) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeliverOutbox sends every alert that is due. Failures are retried with
+// exponential backoff on later calls, or after the Retry-After the service
+// asked for, until MaxDeliveryAttempts is reached. Notifiers in digest mode
+// get the due alerts combined into one message (see DigestConfig). A
+// delivery cut short by ctx is left as it was, without using up an attempt.
+func DeliverOutbox(ctx context.Context, writer *DBWriter, config *Config) {
+	now := time.Now()
+	var entries []*OutboxEntry
+	held := make(map[string]bool)
+	err := writer.Do(ctx, func(db *sql.DB) error {
+		if _, err := db.ExecContext(ctx, "DELETE FROM outbox WHERE status = ? AND sent_at < ?",
+			OutboxSent, sqliteTime(now.Add(-outboxKeepSent))); err != nil {
+			return err
+		}
+
+		var err error
+		entries, err = dueOutbox(ctx, db, now)
+		if err != nil {
+			return err
+		}
+
+		for _, notifier := range Notifiers(config) {
+			window := DigestFor(config, notifier).window()
+			if window == 0 {
+				continue
+			}
+			ripe, err := outboxWaited(ctx, db, notifier, now.Add(-window))
+			if err != nil {
+				return err
+			}
+			held[notifier] = !ripe
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("  Failed to read the alert outbox: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	configured := make(map[string]Notifier)
+	for _, notifier := range BuildNotifiers(config) {
+		configured[notifier.Name()] = notifier
+	}
+
+	// A rate-limited notifier is skipped for the rest of this delivery.
+	limited := make(map[string]bool)
+	sent, failed := 0, 0
+
+	for _, batch := range batchOutbox(entries, config, held) {
+		if ctx.Err() != nil || limited[batch[0].Notifier] {
+			continue
+		}
+
+		var deliveryErr error
+		if notifier, exists := configured[batch[0].Notifier]; exists {
+			deliveryErr = DeliverMessage(ctx, notifier, renderBatch(batch))
+		} else {
+			deliveryErr = fmt.Errorf("%s is no longer configured", batch[0].Notifier)
+		}
+		if deliveryErr != nil && ctx.Err() != nil {
+			log.Printf("🛑 Delivery to %s interrupted, it is retried on the next run", batch[0].Notifier)
+			continue
+		}
+
+		// Recording the result must not be cut short by shutdown, or a sent
+		// alert would be sent again.
+		recordCtx := context.WithoutCancel(ctx)
+		err := writer.Do(recordCtx, func(db *sql.DB) error {
+			for _, entry := range batch {
+				var err error
+				if deliveryErr == nil {
+					err = markSent(recordCtx, db, entry.ID)
+				} else {
+					err = markFailed(recordCtx, db, entry, deliveryErr)
+				}
+				if err != nil {
+					return fmt.Errorf("outbox entry %d: %w", entry.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("  Failed to update the outbox: %v", err)
+		}
+
+		if deliveryErr == nil {
+			sent += len(batch)
+			continue
+		}
+		failed += len(batch)
+
+		var rejected *DeliveryError
+		if errors.As(deliveryErr, &rejected) && rejected.RetryAfter > 0 {
+			limited[batch[0].Notifier] = true
+		}
+	}
+
+	log.Printf("📮 Outbox: %d delivered, %d failed, %d left for later", sent, failed, len(entries)-sent-failed)
+}
+
+// batchOutbox splits the due entries into deliveries, in the order of their
+// first entry: one per entry, or one per notifier for those whose digest
+// settings combine them. Notifiers in held are left out.
+func batchOutbox(entries []*OutboxEntry, config *Config, held map[string]bool) [][]*OutboxEntry {
+	byNotifier := make(map[string][]*OutboxEntry)
+	var order []string
+	for _, entry := range entries {
+		if held[entry.Notifier] {
+			continue
+		}
+		if _, seen := byNotifier[entry.Notifier]; !seen {
+			order = append(order, entry.Notifier)
+		}
+		byNotifier[entry.Notifier] = append(byNotifier[entry.Notifier], entry)
+	}
+
+	combined := make(map[string]bool)
+	for notifier, notifierEntries := range byNotifier {
+		combined[notifier] = DigestFor(config, notifier).combines(len(notifierEntries))
+	}
+
+	var batches [][]*OutboxEntry
+	for _, entry := range entries {
+		switch {
+		case held[entry.Notifier]:
+		case combined[entry.Notifier]:
+			if notifierEntries := byNotifier[entry.Notifier]; notifierEntries[0] == entry {
+				batches = append(batches, notifierEntries)
+			}
+		default:
+			batches = append(batches, []*OutboxEntry{entry})
+		}
+	}
+	return batches
+}
+
+// renderBatch renders a single entry as its alert and several as a digest.
+// The delivery ID lists the entries, so it is stable across retries as long
+// as the batch is.
+func renderBatch(batch []*OutboxEntry) *Message {
+	messages := make([]*Message, len(batch))
+	ids := make([]string, len(batch))
+	for i, entry := range batch {
+		messages[i] = RenderAlert(entry.Alert)
+		messages[i].ID = strconv.FormatInt(entry.ID, 10)
+		ids[i] = messages[i].ID
+	}
+	if len(batch) == 1 {
+		return messages[0]
+	}
+
+	log.Printf("📰 Combining %d alerts for %s into a digest", len(batch), batch[0].Notifier)
+	digest := RenderDigest(messages)
+	digest.ID = strings.Join(ids, ",")
+	return digest
+}
+
+// outboxWaited reports whether the notifier's oldest pending entry was
+// queued before cutoff.
+func outboxWaited(ctx context.Context, db DBTX, notifier string, cutoff time.Time) (bool, error) {
+	var waited bool
+	err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(MIN(created_at) <= ?, 0) FROM outbox WHERE status = ? AND notifier = ?",
+		sqliteTime(cutoff), OutboxPending, notifier).Scan(&waited)
+	return waited, err
+}
+
+func markSent(ctx context.Context, db DBTX, id int64) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = '', sent_at = CURRENT_TIMESTAMP WHERE id = ?",
+		OutboxSent, id)
+	return err
+}
+
+func markFailed(ctx context.Context, db DBTX, entry *OutboxEntry, deliveryErr error) error {
+	attempts := entry.Attempts + 1
+	if attempts >= MaxDeliveryAttempts {
+		log.Printf("  ☠️ Giving up on %s alert for %s after %d attempts", entry.Notifier, entry.Alert.Program.Name, attempts)
+		_, err := db.ExecContext(ctx, "UPDATE outbox SET status = ?, attempts = ?, last_error = ? WHERE id = ?",
+			OutboxDead, attempts, deliveryErr.Error(), entry.ID)
+		return err
+	}
+
+	backoff := min(outboxBaseBackoff<<(attempts-1), outboxMaxBackoff)
+	var rejected *DeliveryError
+	if errors.As(deliveryErr, &rejected) && rejected.RetryAfter > backoff {
+		backoff = rejected.RetryAfter
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE outbox SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		attempts, deliveryErr.Error(), sqliteTime(time.Now().Add(backoff)), entry.ID)
+	return err
+}
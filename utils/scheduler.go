@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Wall-clock times are compared with the monotonic reading stripped, so a
+// tick missed while the machine was suspended is noticed on the next poll.
+const schedulerPoll = 30 * time.Second
+
+// MinWatchInterval is the shortest interval a platform is synced at.
+const MinWatchInterval = time.Minute
+
+type Scheduler struct {
+	jitter time.Duration
+	jobs   []*scheduledJob
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	next     time.Time
+}
+
+func NewScheduler(jitter time.Duration) *Scheduler {
+	return &Scheduler{jitter: jitter}
+}
+
+func (s *Scheduler) Add(name string, interval time.Duration) {
+	if interval < MinWatchInterval {
+		log.Printf(" 🍀 Interval %v for %s is too short, using %v", interval, strings.Title(name), MinWatchInterval)
+		interval = MinWatchInterval
+	}
+	s.jobs = append(s.jobs, &scheduledJob{
+		name:     name,
+		interval: interval,
+		next:     wallNow(),
+	})
+}
+
+func (s *Scheduler) Run(ctx context.Context, run func(due []string)) {
+	for {
+		now := wallNow()
+
+		var due []*scheduledJob
+		for _, job := range s.jobs {
+			if !now.Before(job.next) {
+				if missed := now.Sub(job.next); missed > job.interval {
+					log.Printf("⏰ %s missed its schedule by %v, catching up", strings.Title(job.name), missed.Round(time.Second))
+				}
+				due = append(due, job)
+			}
+		}
+
+		if len(due) > 0 {
+			names := make([]string, len(due))
+			for i, job := range due {
+				names[i] = job.name
+			}
+			run(names)
+
+			finished := wallNow()
+			for _, job := range due {
+				job.next = finished.Add(job.interval + s.randomJitter())
+				log.Printf("⏰ Next %s run at %s", strings.Title(job.name), job.next.Format(time.RFC3339))
+			}
+		}
+
+		timer := time.NewTimer(s.untilNext())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) untilNext() time.Duration {
+	wait := schedulerPoll
+	now := wallNow()
+	for _, job := range s.jobs {
+		if until := job.next.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+func (s *Scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
+
+func wallNow() time.Time {
+	return time.Now().Round(0)
+}
+
+func ParseDuration(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Printf(" 🍀 Invalid duration %q, using %v", value, fallback)
+		return fallback
+	}
+	return duration
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Target is a single scope entry with the attributes that platforms expose
+// for it. Not every platform fills in every attribute.
+type Target struct {
+	Asset             string `json:"asset"`
+	Type              string `json:"type,omitempty"`
+	Category          string `json:"category,omitempty"`
+	Tier              string `json:"tier,omitempty"`
+	EligibleForBounty bool   `json:"eligible_for_bounty,omitempty"`
+	MaxSeverity       string `json:"max_severity,omitempty"`
+	Instruction       string `json:"instruction,omitempty"`
+
+	// legacy marks targets read from a scope stored as plain strings, whose
+	// attributes are unknown rather than empty.
+	legacy bool
+}
+
+func (t Target) Key() string {
+	return ScopeKey(t.Asset, t.Type)
+}
+
+func (t Target) String() string {
+	if t.Type == "" {
+		return t.Asset
+	}
+	return fmt.Sprintf("%s (%s)", t.Asset, t.Type)
+}
+
+type ScopeChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// AttributeChange is a change to one attribute of a target that is in scope
+// both before and after, e.g. max_severity raised from high to critical.
+type AttributeChange struct {
+	Target    string `json:"target"`
+	Attribute string `json:"attribute"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+// ScopeKey identifies a scope entry by its asset and type rather than by
+// its position in the upstream feed, so reordering a program's targets
+// never shows up as a change.
+func ScopeKey(asset, targetType string) string {
+	asset = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(asset)), "/")
+	return strings.ToLower(strings.TrimSpace(targetType)) + "|" + asset
+}
+
+func scopeAsset(key string) string {
+	_, asset, _ := strings.Cut(key, "|")
+	return asset
+}
+
+// parseTarget splits an "asset (type)" string, as scopes were stored before
+// Target existed, back into its asset and type.
+func parseTarget(value string) Target {
+	if strings.HasSuffix(value, ")") {
+		if i := strings.LastIndex(value, " ("); i >= 0 {
+			return Target{Asset: value[:i], Type: value[i+2 : len(value)-1], legacy: true}
+		}
+	}
+	return Target{Asset: value, legacy: true}
+}
+
+func SerializeScope(scope map[string]Target) (string, error) {
+	if scope == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(scope)
+	return string(data), err
+}
+
+// IsCurrentScope reports whether scopeStr is stored as targets rather than
+// in one of the older formats read by DeserializeScope.
+func IsCurrentScope(scopeStr string) bool {
+	var scope map[string]Target
+	return scopeStr != "" && json.Unmarshal([]byte(scopeStr), &scope) == nil
+}
+
+// DeserializeScope also reads the older formats: "asset (type)" strings
+// keyed by identity or, before that, by position in the feed (e.g.
+// "target-3"). It fails on legacy entries that are not targets at all, such
+// as the raw JSON that Bugcrowd scopes were once stored as.
+func DeserializeScope(scopeStr string) (map[string]Target, error) {
+	scope := make(map[string]Target)
+	if scopeStr == "" {
+		return scope, nil
+	}
+	if err := json.Unmarshal([]byte(scopeStr), &scope); err == nil {
+		return scope, nil
+	}
+
+	var legacy map[string]string
+	if err := json.Unmarshal([]byte(scopeStr), &legacy); err != nil {
+		return scope, err
+	}
+	scope = make(map[string]Target, len(legacy))
+	for _, value := range legacy {
+		if trimmed := strings.TrimSpace(value); trimmed == "" || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return make(map[string]Target), fmt.Errorf("stored scope entry is not a target: %s", shorten(trimmed, 40))
+		}
+		target := parseTarget(value)
+		scope[target.Key()] = target
+	}
+	return scope, nil
+}
+
+func SortedScopeValues(scope map[string]Target) []string {
+	values := make([]string, 0, len(scope))
+	for _, target := range scope {
+		values = append(values, target.String())
+	}
+	sort.Strings(values)
+	return values
+}
+
+func SortedTargets(scope map[string]Target) []Target {
+	targets := make([]Target, 0, len(scope))
+	for _, target := range scope {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets
+}
+
+// CompareScopes matches targets by ScopeKey. A target whose asset is still
+// present under another type (e.g. after HackerOne reclassifies it) is
+// reported as changed rather than as removed and added; a target that kept
+// its key but not its attributes is reported as AttributeChanges. Attributes
+// of targets read from the legacy format are not compared, and neither is
+// their type when it was stored empty (HackerOne's were saved as "asset ()").
+func CompareScopes(oldScope, newScope map[string]Target) ([]string, []string, []ScopeChange, []AttributeChange) {
+	var newScopes, removedScopes []string
+	var changedScopes []ScopeChange
+	var attributes []AttributeChange
+
+	added := make(map[string]Target)
+	addedByAsset := make(map[string][]string)
+	for id, newTarget := range newScope {
+		oldTarget, exists := oldScope[id]
+		if !exists {
+			added[id] = newTarget
+			addedByAsset[scopeAsset(id)] = append(addedByAsset[scopeAsset(id)], id)
+			continue
+		}
+		if oldTarget.String() != newTarget.String() {
+			changedScopes = append(changedScopes, ScopeChange{
+				Old: oldTarget.String(),
+				New: newTarget.String(),
+			})
+		}
+		attributes = append(attributes, compareTargets(oldTarget, newTarget)...)
+	}
+
+	for id, oldTarget := range oldScope {
+		if _, exists := newScope[id]; exists {
+			continue
+		}
+		if candidates := addedByAsset[scopeAsset(id)]; len(candidates) > 0 {
+			if !oldTarget.legacy || oldTarget.Type != "" {
+				changedScopes = append(changedScopes, ScopeChange{
+					Old: oldTarget.String(),
+					New: added[candidates[0]].String(),
+				})
+			}
+			delete(added, candidates[0])
+			addedByAsset[scopeAsset(id)] = candidates[1:]
+			continue
+		}
+		removedScopes = append(removedScopes, oldTarget.String())
+	}
+
+	for _, newTarget := range added {
+		newScopes = append(newScopes, newTarget.String())
+	}
+
+	sort.Strings(newScopes)
+	sort.Strings(removedScopes)
+	sort.Slice(changedScopes, func(i, j int) bool { return changedScopes[i].New < changedScopes[j].New })
+	sort.Slice(attributes, func(i, j int) bool {
+		if attributes[i].Target != attributes[j].Target {
+			return attributes[i].Target < attributes[j].Target
+		}
+		return attributes[i].Attribute < attributes[j].Attribute
+	})
+	return newScopes, removedScopes, changedScopes, attributes
+}
+
+func compareTargets(oldTarget, newTarget Target) []AttributeChange {
+	if oldTarget.legacy {
+		return nil
+	}
+
+	var changes []AttributeChange
+	compare := func(attribute, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, AttributeChange{
+				Target:    newTarget.String(),
+				Attribute: attribute,
+				Old:       oldValue,
+				New:       newValue,
+			})
+		}
+	}
+
+	compare("eligible_for_bounty", fmt.Sprint(oldTarget.EligibleForBounty), fmt.Sprint(newTarget.EligibleForBounty))
+	compare("max_severity", oldTarget.MaxSeverity, newTarget.MaxSeverity)
+	compare("category", oldTarget.Category, newTarget.Category)
+	compare("tier", oldTarget.Tier, newTarget.Tier)
+	compare("instruction", oldTarget.Instruction, newTarget.Instruction)
+	return changes
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "PEWPEW_"
+
+var (
+	envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+	envNameChars = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// applyEnvironment overrides every config key from its PEWPEW_* variable
+// (e.g. PEWPEW_TELEGRAM_BOT_TOKEN for telegram.bot_token), then resolves
+// ${VAR} and file: references in fields tagged secret:"true".
+func applyEnvironment(config *Config) ConfigProblems {
+	var problems ConfigProblems
+	walkConfig(reflect.ValueOf(config).Elem(), "", "", func(path string, v reflect.Value, tag reflect.StructTag) {
+		if value, exists := os.LookupEnv(EnvName(path)); exists {
+			if err := setFromString(v, value); err != nil {
+				problems.add(path, "invalid value in %s: %v", EnvName(path), err)
+				return
+			}
+		}
+
+		if tag.Get("secret") == "true" && v.Kind() == reflect.String {
+			resolved, err := ResolveSecret(v.String())
+			if err != nil {
+				problems.add(path, "%v", err)
+				v.SetString("")
+				return
+			}
+			v.SetString(resolved)
+		}
+	})
+	return problems
+}
+
+func EnvName(path string) string {
+	name := envNameChars.ReplaceAllString(strings.ToUpper(path), "_")
+	return envPrefix + strings.Trim(name, "_")
+}
+
+// ResolveSecret expands "file:/path" to the trimmed contents of the file and
+// ${VAR} (or ${VAR:-default}) to the environment variable.
+func ResolveSecret(value string) (string, error) {
+	if path, isFile := strings.CutPrefix(value, "file:"); isFile {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var missing []string
+	resolved := envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		match := envReference.FindStringSubmatch(reference)
+		if env, exists := os.LookupEnv(match[1]); exists {
+			return env
+		}
+		if match[2] != "" {
+			return match[3]
+		}
+		missing = append(missing, match[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// walkConfig calls visit for every scalar field reachable from v, keyed by
+// its JSON path. Map values are copied out and written back so that visit
+// can modify them.
+func walkConfig(v reflect.Value, path string, tag reflect.StructTag, visit func(path string, v reflect.Value, tag reflect.StructTag)) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			walkConfig(v.Field(i), joinPath(path, name), field.Tag, visit)
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			walkConfig(value, joinPath(path, iter.Key().String()), tag, visit)
+			v.SetMapIndex(iter.Key(), value)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkConfig(v.Index(i), fmt.Sprintf("%s[%d]", path, i), tag, visit)
+		}
+
+	case reflect.String, reflect.Bool, reflect.Int:
+		visit(path, v, tag)
+	}
+}
+
+func setFromString(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	}
+	return nil
+}
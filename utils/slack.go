@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slack rejects blocks over these sizes (invalid_blocks) rather than
+// truncating them.
+const (
+	slackHeaderLength  = 150
+	slackSectionLength = 3000
+	slackFieldLength   = 2000
+	slackMaxFields     = 10
+	slackMaxItems      = 10
+)
+
+type SlackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+// SlackAttachment only carries the color bar; the content is in Blocks.
+type SlackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+type SlackBlock struct {
+	Type     string       `json:"type"`
+	Text     *SlackText   `json:"text,omitempty"`
+	Fields   []*SlackText `json:"fields,omitempty"`
+	Elements []*SlackText `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+func init() {
+	RegisterNotifier("slack", NotifierType{
+		Fields: []string{"webhook_url"},
+		Validate: func(config NotifierConfig, problems *ConfigProblems) {
+			u, err := url.Parse(config.WebhookURL)
+			if config.WebhookURL == "" {
+				problems.add("webhook_url", "required")
+			} else if err != nil || u.Scheme != "https" || u.Host != "hooks.slack.com" || !strings.HasPrefix(u.Path, "/services/") {
+				problems.add("webhook_url", "not a Slack incoming webhook URL (expected https://hooks.slack.com/services/...)")
+			}
+		},
+		New: func(config NotifierConfig) Notifier {
+			return &slackNotifier{name: config.Name, webhookURL: config.WebhookURL}
+		},
+	})
+}
+
+type slackNotifier struct {
+	name       string
+	webhookURL string
+}
+
+func (n *slackNotifier) Name() string {
+	return n.name
+}
+
+func (n *slackNotifier) Send(ctx context.Context, message *Message) error {
+	return sendSlackMessage(ctx, n.webhookURL, createSlackMessage(message))
+}
+
+func createSlackMessage(message *Message) *SlackMessage {
+	headline := message.Icon + " " + message.Title
+	blocks := []SlackBlock{{
+		Type: "header",
+		Text: &SlackText{Type: "plain_text", Text: shorten(headline, slackHeaderLength-3), Emoji: true},
+	}}
+
+	var description string
+	switch message.Kind {
+	case MessageStartup:
+		description = "*Hello Hunter!* 👋\nI'm now monitoring your favorite bug bounty platforms for new programs, scope changes, and updates."
+	case MessageDigest:
+		description = slackEscape(message.Summary)
+	default:
+		program := message.Program
+		platform := strings.Title(program.Platform)
+		link := fmt.Sprintf("<%s|%s>", program.URL, slackEscape(program.Name))
+		switch message.Kind {
+		case MessageRemoved:
+			description = fmt.Sprintf("*%s* has been removed from *%s*\n💔 We'll miss this one!", link, platform)
+		case MessageNew:
+			description = fmt.Sprintf("*%s* just launched on *%s*!\n📝 Type: `%s`\n🚀 Happy hunting!", link, platform, slackEscape(program.Type))
+		default:
+			description = fmt.Sprintf("*%s* has been updated on *%s*\n👀 Check what's new!", link, platform)
+		}
+	}
+	blocks = append(blocks, slackSection(description))
+
+	// Values share one block as fields; lists get a block each.
+	var fields []*SlackText
+	for _, section := range message.Sections {
+		heading := "*" + slackEscape(section.Heading()) + "*"
+		if section.Items == nil {
+			value := fmt.Sprintf("%s\n`%s`", heading, slackEscape(section.Value))
+			fields = append(fields, &SlackText{Type: "mrkdwn", Text: shorten(value, slackFieldLength-3)})
+			continue
+		}
+		if message.Kind == MessageStartup {
+			fields = append(fields, &SlackText{Type: "mrkdwn", Text: heading + "\n• " + strings.Join(section.Items, "\n• ")})
+			continue
+		}
+		blocks = append(blocks, slackSection(heading+"\n```"+slackItems(section.Items, slackSectionLength-len(heading)-8)+"```"))
+	}
+	for len(fields) > 0 {
+		count := min(len(fields), slackMaxFields)
+		blocks = append(blocks, SlackBlock{Type: "section", Fields: fields[:count]})
+		fields = fields[count:]
+	}
+
+	blocks = append(blocks, SlackBlock{
+		Type:     "context",
+		Elements: []*SlackText{{Type: "mrkdwn", Text: "🍀 PewPew Watcher • <https://github.com/M-thefl|GitHub: M-thefl>"}},
+	})
+
+	// Text is what Slack shows in notifications and clients without
+	// Block Kit support.
+	text := headline
+	if message.Program != nil {
+		text = fmt.Sprintf("%s (%s)", headline, strings.Title(message.Program.Platform))
+	}
+
+	return &SlackMessage{
+		Text: text,
+		Attachments: []SlackAttachment{{
+			Color:  fmt.Sprintf("#%06X", message.Color()),
+			Blocks: blocks,
+		}},
+	}
+}
+
+func slackSection(text string) SlackBlock {
+	return SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: shorten(text, slackSectionLength-3)}}
+}
+
+// slackItems lists as many items as fit in maxLength, noting how many were
+// left out.
+func slackItems(items []string, maxLength int) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = slackEscape(item)
+	}
+
+	for count := min(len(escaped), slackMaxItems); count > 0; count-- {
+		if text := formatScope(escaped, count); len(text) <= maxLength {
+			return text
+		}
+	}
+	return fmt.Sprintf("%d entries", len(items))
+}
+
+// slackEscape escapes the characters Slack treats as markup in mrkdwn.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func sendSlackMessage(ctx context.Context, webhookURL string, message *SlackMessage) error {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	resp, err := postJSON(ctx, webhookURL, jsonData)
+	if err != nil {
+		// The webhook URL is the credential; keep it out of the logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("slack request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Slack answers with a short error code such as invalid_blocks or
+		// channel_is_archived, and a Retry-After header (in seconds) on 429.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		deliveryErr := &DeliveryError{StatusCode: resp.StatusCode, Reason: strings.TrimSpace(string(body))}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			deliveryErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return deliveryErr
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	telegramBotToken = regexp.MustCompile(`^\d+:[\w-]{30,}$`)
+	telegramChatID   = regexp.MustCompile(`^(-?\d+|@\w{5,})$`)
+)
+
+func init() {
+	RegisterNotifier("telegram", NotifierType{
+		Fields: []string{"bot_token", "chat_id"},
+		Validate: func(config NotifierConfig, problems *ConfigProblems) {
+			if config.BotToken == "" {
+				problems.add("bot_token", "required")
+			} else if !telegramBotToken.MatchString(config.BotToken) {
+				problems.add("bot_token", "not a Telegram bot token (expected <bot id>:<secret> from @BotFather)")
+			}
+			if config.ChatID == "" {
+				problems.add("chat_id", "required")
+			} else if !telegramChatID.MatchString(config.ChatID) {
+				problems.add("chat_id", "not a Telegram chat ID (expected a numeric ID or @channelname)")
+			}
+		},
+		New: func(config NotifierConfig) Notifier {
+			return &telegramNotifier{name: config.Name, botToken: config.BotToken, chatID: config.ChatID}
+		},
+	})
+}
+
+type telegramNotifier struct {
+	name     string
+	botToken string
+	chatID   string
+}
+
+func (n *telegramNotifier) Name() string {
+	return n.name
+}
+
+func (n *telegramNotifier) Send(ctx context.Context, message *Message) error {
+	var text string
+	switch message.Kind {
+	case MessageStartup:
+		text = formatTelegramStartup(message)
+	case MessageDigest:
+		text = formatTelegramDigest(message)
+	default:
+		text = formatTelegramAlert(message)
+	}
+	return sendTelegramMessage(ctx, n.botToken, n.chatID, text)
+}
+
+func formatTelegramAlert(message *Message) string {
+	program := message.Program
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *%s*\n\n*Platform:* %s\n", message.Icon, message.Title, strings.Title(program.Platform))
+	if message.Kind == MessageRemoved {
+		fmt.Fprintf(&b, "*Program:* %s\n*Type:* %s\n\n💔 We'll miss this one!", program.Name, program.Type)
+	} else if message.Kind == MessageNew {
+		fmt.Fprintf(&b, "*Program:* [%s](%s)\n*Type:* `%s`\n\n🚀 Happy hunting!", program.Name, program.URL, program.Type)
+	} else {
+		fmt.Fprintf(&b, "*Program:* [%s](%s)\n*Type:* `%s`\n\n👀 Check what's new!", program.Name, program.URL, program.Type)
+	}
+
+	for _, section := range message.Sections {
+		if section.Items != nil {
+			fmt.Fprintf(&b, "\n\n%s:*\n%s", telegramHeading(section), formatScope(section.Items, 3))
+		} else {
+			fmt.Fprintf(&b, "\n\n%s:* `%s`", telegramHeading(section), section.Value)
+		}
+	}
+
+	b.WriteString("\n\n 🍀*Powered by M-thefl*")
+	return b.String()
+}
+
+// telegramHeading bolds the section title but not its icon.
+func telegramHeading(section Section) string {
+	return section.Icon + " *" + section.Title
+}
+
+func formatTelegramStartup(message *Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚀 *PewPew Watcher Started!*\n\nHello Hunter! I'm now monitoring bug bounty platforms for you.\n\n")
+	for _, section := range message.Sections {
+		fmt.Fprintf(&b, "*%s:* %s\n", section.Title, strings.Join(section.Items, ", "))
+	}
+	b.WriteString("\nStay tuned for real-time updates!\n\n_Crafted by M-thefl_")
+	return b.String()
+}
+
+func formatTelegramDigest(message *Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *%s*\n\n%s", message.Icon, message.Title, message.Summary)
+	for _, section := range message.Sections {
+		fmt.Fprintf(&b, "\n\n%s:*\n%s", telegramHeading(section), formatScope(section.Items, 10))
+	}
+	b.WriteString("\n\n 🍀*Powered by M-thefl*")
+	return b.String()
+}
+
+func sendTelegramMessage(ctx context.Context, botToken, chatID, text string) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
+	payload := map[string]string{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "Markdown",
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	resp, err := postJSON(ctx, url, jsonData)
+	if err != nil {
+		// The request URL embeds the bot token; keep it out of the logs.
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Telegram puts the wait in the body rather than a header.
+		var body struct {
+			Parameters struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return &DeliveryError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: time.Duration(body.Parameters.RetryAfter) * time.Second,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSchema and WebhookSchemaVersion identify the payload format (see
+// docs/webhook.md). The version is raised whenever a field is removed or
+// changes meaning; new fields may be added within a version.
+const (
+	WebhookSchema        = "pewpew-watcher.alert"
+	WebhookSchemaVersion = 1
+)
+
+const (
+	WebhookSignatureHeader = "X-PewPew-Signature"
+	WebhookTimestampHeader = "X-PewPew-Timestamp"
+	WebhookDeliveryHeader  = "X-PewPew-Delivery"
+)
+
+var webhookHeaderName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+type WebhookPayload struct {
+	Schema     string          `json:"schema"`
+	Version    int             `json:"version"`
+	DeliveryID string          `json:"delivery_id,omitempty"`
+	Kind       string          `json:"kind"`
+	SentAt     string          `json:"sent_at"`
+	Program    *WebhookProgram `json:"program,omitempty"`
+	Changes    *WebhookChanges `json:"changes,omitempty"`
+	// Alerts holds the combined alerts of a digest, each a full payload.
+	Alerts []*WebhookPayload `json:"alerts,omitempty"`
+}
+
+// WebhookProgram is the program as it is now, including its full scope.
+type WebhookProgram struct {
+	Key        string   `json:"key"`
+	Platform   string   `json:"platform"`
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Type       string   `json:"type"`
+	Logo       string   `json:"logo,omitempty"`
+	Scope      []Target `json:"scope"`
+	OutOfScope []Target `json:"out_of_scope"`
+	Reward     Reward   `json:"reward"`
+}
+
+// WebhookChanges is only set for the updated kind. Every field is omitted
+// when that part of the program did not change (or its notification flag is
+// off).
+type WebhookChanges struct {
+	Name       *WebhookValueChange  `json:"name,omitempty"`
+	URL        *WebhookValueChange  `json:"url,omitempty"`
+	Type       *WebhookValueChange  `json:"type,omitempty"`
+	Scope      *WebhookScopeDiff    `json:"scope,omitempty"`
+	OutOfScope *WebhookScopeDiff    `json:"out_of_scope,omitempty"`
+	Attributes []AttributeChange    `json:"attributes,omitempty"`
+	Reward     *WebhookRewardChange `json:"reward,omitempty"`
+}
+
+type WebhookValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type WebhookScopeDiff struct {
+	Added   []string      `json:"added,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+	Changed []ScopeChange `json:"changed,omitempty"`
+}
+
+type WebhookRewardChange struct {
+	Old *Reward `json:"old"`
+	New *Reward `json:"new"`
+}
+
+func init() {
+	RegisterNotifier("webhook", NotifierType{
+		Fields: []string{"url", "secret", "headers"},
+		Validate: func(config NotifierConfig, problems *ConfigProblems) {
+			if config.URL == "" {
+				problems.add("url", "required")
+			} else if u, err := url.Parse(config.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems.add("url", "not an http(s) URL")
+			}
+			if config.Secret == "" {
+				problems.add("secret", "required to sign the requests")
+			}
+
+			names := make([]string, 0, len(config.Headers))
+			for name := range config.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !webhookHeaderName.MatchString(name) {
+					problems.add("headers", "%q is not a valid header name", name)
+				} else if lower := strings.ToLower(name); lower == "content-type" || strings.HasPrefix(lower, "x-pewpew-") {
+					problems.add("headers", "%s is set by pewpew-watcher and cannot be overridden", name)
+				}
+			}
+		},
+		New: func(config NotifierConfig) Notifier {
+			return &webhookNotifier{name: config.Name, url: config.URL, secret: config.Secret, headers: config.Headers}
+		},
+	})
+}
+
+type webhookNotifier struct {
+	name    string
+	url     string
+	secret  string
+	headers map[string]string
+}
+
+func (n *webhookNotifier) Name() string {
+	return n.name
+}
+
+func (n *webhookNotifier) Send(ctx context.Context, message *Message) error {
+	body, err := json.Marshal(NewWebhookPayload(message, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range n.headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pewpew-watcher")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(n.secret, timestamp, body))
+	if message.ID != "" {
+		req.Header.Set(WebhookDeliveryHeader, message.ID)
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		// The URL may carry credentials in its query; keep it out of the logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("webhook request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		deliveryErr := &DeliveryError{StatusCode: resp.StatusCode, Reason: strings.TrimSpace(string(reason))}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			deliveryErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return deliveryErr
+	}
+
+	return nil
+}
+
+// SignWebhook returns the signature header value for body sent at
+// timestamp: "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Covering the timestamp lets receivers reject replayed requests.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewWebhookPayload(message *Message, sentAt time.Time) *WebhookPayload {
+	payload := &WebhookPayload{
+		Schema:     WebhookSchema,
+		Version:    WebhookSchemaVersion,
+		DeliveryID: message.ID,
+		Kind:       message.Kind,
+		SentAt:     sentAt.UTC().Format(time.RFC3339),
+	}
+	for _, alert := range message.Digest {
+		payload.Alerts = append(payload.Alerts, NewWebhookPayload(alert, sentAt))
+	}
+	if message.Program == nil {
+		return payload
+	}
+
+	program := message.Program
+	scope, _ := DeserializeScope(program.Scope)
+	outOfScope, _ := DeserializeScope(program.OutOfScope)
+	reward, _ := DeserializeReward(program.Reward)
+	payload.Program = &WebhookProgram{
+		Key:        program.Key,
+		Platform:   program.Platform,
+		Name:       program.Name,
+		URL:        program.URL,
+		Type:       program.Type,
+		Logo:       program.Logo,
+		Scope:      nonNil(SortedTargets(scope)),
+		OutOfScope: nonNil(SortedTargets(outOfScope)),
+		Reward:     reward,
+	}
+
+	if message.Kind != MessageUpdated || message.Alert == nil {
+		return payload
+	}
+
+	alert := message.Alert
+	changes := &WebhookChanges{Attributes: alert.ChangedAttributes}
+	if alert.PreviousName != "" {
+		changes.Name = &WebhookValueChange{Old: alert.PreviousName, New: program.Name}
+	}
+	if alert.PreviousURL != "" {
+		changes.URL = &WebhookValueChange{Old: alert.PreviousURL, New: program.URL}
+	}
+	if alert.NewType != "" {
+		changes.Type = &WebhookValueChange{Old: alert.PreviousType, New: alert.NewType}
+	}
+	if len(alert.NewScope) > 0 || len(alert.RemovedScope) > 0 || len(alert.ChangedScope) > 0 {
+		changes.Scope = &WebhookScopeDiff{Added: alert.NewScope, Removed: alert.RemovedScope, Changed: alert.ChangedScope}
+	}
+	if len(alert.NewOutOfScope) > 0 || len(alert.RemovedOutOfScope) > 0 {
+		changes.OutOfScope = &WebhookScopeDiff{Added: alert.NewOutOfScope, Removed: alert.RemovedOutOfScope}
+	}
+	if alert.Reward != nil {
+		changes.Reward = &WebhookRewardChange{Old: alert.PreviousReward, New: alert.Reward}
+	}
+	payload.Changes = changes
+
+	return payload
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil(targets []Target) []Target {
+	if targets == nil {
+		return []Target{}
+	}
+	return targets
+}
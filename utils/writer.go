@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// DBWriter funnels every database job through a single goroutine so that
+// concurrent platform syncs never contend for the SQLite write lock.
+type DBWriter struct {
+	db        *sql.DB
+	jobs      chan writeJob
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type writeJob struct {
+	fn     func(db *sql.DB) error
+	result chan error
+}
+
+func NewDBWriter(db *sql.DB) *DBWriter {
+	writer := &DBWriter{
+		db:   db,
+		jobs: make(chan writeJob),
+		done: make(chan struct{}),
+	}
+	go writer.loop()
+	return writer
+}
+
+func (w *DBWriter) loop() {
+	defer close(w.done)
+	for job := range w.jobs {
+		job.result <- job.fn(w.db)
+	}
+}
+
+// Do waits for the writer and runs fn. Once fn has started it runs to
+// completion; ctx only abandons jobs that are still queued.
+func (w *DBWriter) Do(ctx context.Context, fn func(db *sql.DB) error) error {
+	result := make(chan error, 1)
+	select {
+	case w.jobs <- writeJob{fn: fn, result: result}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-result
+}
+
+func (w *DBWriter) Close() {
+	w.closeOnce.Do(func() {
+		close(w.jobs)
+		<-w.done
+	})
+}